@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	colorpalette "image/color/palette"
+	imagedraw "image/draw"
+	"image/gif"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+// Animation
+// -----------------------------------------------------------------------------
+
+// animate plots each input in cfg.Inputs as one frame of an animation, such as
+// the numbered outputs of a simulation, and saves the frames as an animated
+// GIF to cfg.Animate, showing each for cfg.FrameDelay. The automatic axis
+// ranges are widened to cover every frame first, so the axes hold still while
+// the data moves. Each frame is titled after its input unless cfg.Title or a
+// title directive is given. When the terminal can show the plot, the frames
+// are also played there once as they are rendered. Statistics and text tables
+// are printed for the data of all frames, as for a single plot.
+func animate(cfg Config) error {
+	sets, err := readInputs(cfg)
+	if err != nil {
+		return err
+	}
+	if err := printReports(sets, cfg); err != nil {
+		return err
+	}
+	frames := make([]Dataset, len(sets))
+	frameCfgs := make([]Config, len(sets))
+	for i := range sets {
+		frames[i] = combineDatasets(sets[i:i+1], cfg)
+		frameCfgs[i] = cfg
+		frameCfgs[i].Title = firstNonEmpty(cfg.Title, frames[i].Meta.Title, inputBase(cfg.Inputs[i]))
+	}
+
+	// The automatic range of each axis is the union of the frames' ranges
+	xmin, xmax := math.Inf(1), math.Inf(-1)
+	ymin, ymax := math.Inf(1), math.Inf(-1)
+	for i, data := range frames {
+		start := time.Now()
+		p, err := newPlot(data, frameCfgs[i])
+		if err != nil {
+			return fmt.Errorf("frame %s: %w", cfg.Inputs[i], err)
+		}
+		cfg.Timings.since("build", start)
+		xmin, xmax = math.Min(xmin, p.X.Min), math.Max(xmax, p.X.Max)
+		ymin, ymax = math.Min(ymin, p.Y.Min), math.Max(ymax, p.Y.Max)
+	}
+	for i := range frameCfgs {
+		fc := &frameCfgs[i]
+		if math.IsNaN(fc.XMin) {
+			fc.XMin = xmin
+		}
+		if math.IsNaN(fc.XMax) {
+			fc.XMax = xmax
+		}
+		if math.IsNaN(fc.YMin) {
+			fc.YMin = ymin
+		}
+		if math.IsNaN(fc.YMax) {
+			fc.YMax = ymax
+		}
+	}
+
+	// Detect the protocol once for all frames, rather than querying the
+	// terminal before each one
+	cfg = resolveProtocol(cfg)
+	anim := &gif.GIF{}
+	delay := int(cfg.FrameDelay.Round(10*time.Millisecond) / (10 * time.Millisecond)) // In 1/100 s
+	for i, data := range frames {
+		fc := frameCfgs[i]
+		fc.Protocol, fc.Sixel = cfg.Protocol, cfg.Sixel
+		p, err := newPlot(data, fc)
+		if err != nil {
+			return fmt.Errorf("frame %s: %w", cfg.Inputs[i], err)
+		}
+		img := rasterFigure(fc, p.Draw).Image()
+		anim.Image = append(anim.Image, paletted(img))
+		anim.Delay = append(anim.Delay, delay)
+
+		if cfg.Protocol != "none" {
+			start := time.Now()
+			clearScreen()
+			if err := displayImage("", img, data, fc); err != nil {
+				return fmt.Errorf("displaying frame %s: %w", cfg.Inputs[i], err)
+			}
+			cfg.Timings.since("display", start)
+			if i < len(frames)-1 {
+				time.Sleep(time.Until(start.Add(cfg.FrameDelay)))
+			}
+		}
+	}
+	if cfg.NoFile {
+		return nil
+	}
+
+	defer cfg.Timings.since("save", time.Now())
+	if dir := filepath.Dir(cfg.Animate); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("creating output directory %q: %w", dir, err)
+		}
+	}
+	f, err := os.Create(cfg.Animate)
+	if err != nil {
+		return err
+	}
+	if err := gif.EncodeAll(f, anim); err != nil {
+		f.Close()
+		return fmt.Errorf("encode animation %s: %w", cfg.Animate, err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	logInfo(cfg, "Animation of %d frames saved to: %s", len(frames), cfg.Animate)
+	return nil
+}
+
+// paletted converts img to the 256 colors of a GIF frame, mapping each pixel
+// to the nearest color of the Plan 9 palette. Plots are mostly flat colors,
+// which dithering would only speckle.
+func paletted(img image.Image) *image.Paletted {
+	dst := image.NewPaletted(img.Bounds(), colorpalette.Plan9)
+	imagedraw.Draw(dst, dst.Rect, img, img.Bounds().Min, imagedraw.Src)
+	return dst
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// -----------------------------------------------------------------------------
+// Reference Lines
+// -----------------------------------------------------------------------------
+
+// addRefLines adds the -hline and -vline reference lines to p as thin dashed
+// lines in cfg.Colors.RefLine, or a faded foreground color when unset.
+func addRefLines(p *plot.Plot, cfg Config) error {
+	c := cfg.Colors.RefLine
+	if c == nil {
+		c = fade(cfg.Colors.Foreground, 0x99)
+	}
+	style := draw.LineStyle{
+		Color:  c,
+		Width:  vg.Points(1),
+		Dashes: []vg.Length{vg.Points(4), vg.Points(3)},
+	}
+
+	for _, y := range cfg.HLines {
+		if cfg.LogY && y <= 0 {
+			return fmt.Errorf("-hline %g is not positive, as the log Y axis requires", y)
+		}
+		p.Add(refLine{value: y, LineStyle: style})
+	}
+	for _, x := range cfg.VLines {
+		if cfg.LogX && x <= 0 {
+			return fmt.Errorf("-vline %g is not positive, as the log X axis requires", x)
+		}
+		p.Add(refLine{value: x, vertical: true, LineStyle: style})
+	}
+	return nil
+}
+
+// refLine is a plotter drawing a line across the whole data area at a fixed
+// X or Y value. Only that value counts toward the axis ranges, so the line
+// spans the plot however the other axis is scaled.
+type refLine struct {
+	value    float64
+	vertical bool // Whether value is an X value
+	draw.LineStyle
+}
+
+// Plot implements the plot.Plotter interface.
+func (l refLine) Plot(c draw.Canvas, plt *plot.Plot) {
+	trX, trY := plt.Transforms(&c)
+	var pts []vg.Point
+	if l.vertical {
+		x := trX(l.value)
+		pts = []vg.Point{{X: x, Y: c.Min.Y}, {X: x, Y: c.Max.Y}}
+	} else {
+		y := trY(l.value)
+		pts = []vg.Point{{X: c.Min.X, Y: y}, {X: c.Max.X, Y: y}}
+	}
+	c.StrokeLines(l.LineStyle, c.ClipLinesXY(pts)...)
+}
+
+// DataRange implements the plot.DataRanger interface.
+func (l refLine) DataRange() (xmin, xmax, ymin, ymax float64) {
+	inf := math.Inf(1)
+	if l.vertical {
+		return l.value, l.value, inf, -inf
+	}
+	return inf, -inf, l.value, l.value
+}
+
+// -----------------------------------------------------------------------------
+// Text Annotations
+// -----------------------------------------------------------------------------
+
+// Annotation is a text label placed at a data coordinate.
+type Annotation struct {
+	X, Y float64
+	Text string
+}
+
+// parseAnnotation parses an -annotate value of the form "x,y,text". The text
+// is everything after the second comma, so it may itself contain commas.
+func parseAnnotation(s string) (Annotation, error) {
+	parts := strings.SplitN(s, ",", 3)
+	if len(parts) != 3 || strings.TrimSpace(parts[2]) == "" {
+		return Annotation{}, fmt.Errorf("expected x,y,text, got %q", s)
+	}
+	x, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil {
+		return Annotation{}, fmt.Errorf("invalid X value %q", parts[0])
+	}
+	y, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return Annotation{}, fmt.Errorf("invalid Y value %q", parts[1])
+	}
+	return Annotation{X: x, Y: y, Text: strings.TrimSpace(parts[2])}, nil
+}
+
+// addAnnotations adds the -annotate labels to p. Each label starts just above
+// and to the right of its coordinate, so that it does not cover a data point
+// drawn there.
+func addAnnotations(p *plot.Plot, cfg Config) error {
+	if len(cfg.Annotations) == 0 {
+		return nil
+	}
+
+	var xyl plotter.XYLabels
+	for _, a := range cfg.Annotations {
+		if (cfg.LogX && a.X <= 0) || (cfg.LogY && a.Y <= 0) {
+			return fmt.Errorf("annotation %q at (%g, %g) is outside the log axis range", a.Text, a.X, a.Y)
+		}
+		xyl.XYs = append(xyl.XYs, plotter.XY{X: a.X, Y: a.Y})
+		xyl.Labels = append(xyl.Labels, a.Text)
+	}
+
+	labels, err := plotter.NewLabels(xyl)
+	if err != nil {
+		return fmt.Errorf("create annotations: %w", err)
+	}
+	text, _, _ := fontSizes(cfg)
+	for i := range labels.TextStyle {
+		labels.TextStyle[i].Color = cfg.Colors.Foreground
+		labels.TextStyle[i].Font.Size = vg.Points(text)
+	}
+	labels.Offset = vg.Point{X: vg.Points(4), Y: vg.Points(4)}
+
+	p.Add(labels)
+	return nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"gonum.org/v1/plot"
+)
+
+// -----------------------------------------------------------------------------
+// Library API
+// -----------------------------------------------------------------------------
+
+// RenderPNG plots points as a single series using the settings in cfg, which
+// typically starts from DefaultConfig, and writes the image to w as PNG.
+// Unlike the command line, it neither touches the filesystem nor exits on
+// errors; input options such as cfg.Inputs are ignored.
+func RenderPNG(points []Point, cfg Config, w io.Writer) error {
+	p, err := renderPoints(points, cfg)
+	if err != nil {
+		return err
+	}
+	_, err = writeFigure(w, "png", cfg, p.Draw)
+	return err
+}
+
+// RenderSixel is like RenderPNG, but writes the image to w as SIXEL graphics
+// for display in a terminal, scaled by cfg.Scale.
+func RenderSixel(points []Point, cfg Config, w io.Writer) error {
+	p, err := renderPoints(points, cfg)
+	if err != nil {
+		return err
+	}
+	return writeSixel(w, rasterFigure(cfg, p.Draw).Image(), cfg)
+}
+
+// renderPoints validates cfg and builds the plot of points for RenderPNG and
+// RenderSixel.
+func renderPoints(points []Point, cfg Config) (*plot.Plot, error) {
+	if err := validateConfig(cfg); err != nil {
+		return nil, err
+	}
+	if len(points) == 0 {
+		return nil, fmt.Errorf("no points to plot")
+	}
+	return newPlot(Dataset{Series: []Series{{Label: "Column 1", Column: 1, Points: points}}}, cfg)
+}
+
+// ParseOptions selects how ReadData parses its input. Empty fields take the
+// command-line defaults; the string fields accept the same values as the flags
+// of the same names.
+type ParseOptions struct {
+	Delimiter string // Field delimiter, as for -delimiter; "auto" when empty
+	Decimal   string // Decimal separator, as for -decimal; "." when empty
+	Comment   string // Comment prefixes, as for -comment; "#%" when empty
+	Header    string // Header row detection, as for -header; "auto" when empty
+	NaN       string // Handling of NaN/Inf values, as for -nan; "skip" when empty
+	XTime     string // Time layout of X values, as for -xtime; numeric when empty
+	Skip      int    // Number of leading non-comment lines to discard
+	Column    int    // Field index of the Y values read; 1 when zero
+	Strict    bool   // Fail on the first malformed line instead of skipping it
+}
+
+// ParseError describes a malformed input line skipped by ReadData.
+type ParseError struct {
+	Line int    // Line number, from 1
+	Text string // Content of the line
+	Err  error  // Why the line was rejected
+}
+
+// Error implements the error interface.
+func (e ParseError) Error() string {
+	return fmt.Sprintf("line %d: %v: %q", e.Line, e.Err, e.Text)
+}
+
+// Unwrap returns the reason the line was rejected.
+func (e ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ReadData parses lines of data from r like the command line does for a file,
+// returning the points of the Y column selected by opts against field 0 as X.
+// Malformed lines are skipped and returned as ParseErrors, unless opts.Strict
+// is set; the error is non-nil only when reading had to stop.
+func ReadData(r io.Reader, opts ParseOptions) ([]Point, []ParseError, error) {
+	cfg := DefaultConfig()
+	cfg.Quiet = true // Report problems through the results, not the log
+	cfg.Skip, cfg.Strict, cfg.XTime = opts.Skip, opts.Strict, opts.XTime
+	for _, o := range []struct {
+		dst *string
+		src string
+	}{
+		{&cfg.Delimiter, opts.Delimiter},
+		{&cfg.Decimal, opts.Decimal},
+		{&cfg.Comment, opts.Comment},
+		{&cfg.Header, opts.Header},
+		{&cfg.NaN, opts.NaN},
+	} {
+		if o.src != "" {
+			*o.dst = o.src
+		}
+	}
+	if opts.Column < 0 {
+		return nil, nil, fmt.Errorf("column index %d must be >= 0", opts.Column)
+	}
+	if opts.Column != 0 {
+		cfg.Columns = []int{opts.Column}
+	}
+	if err := validateConfig(cfg); err != nil {
+		return nil, nil, err
+	}
+
+	parser, err := newLineParser("input", cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	var parseErrs []ParseError
+	parser.onSkip = func(e ParseError) { parseErrs = append(parseErrs, e) }
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if err := parser.parse(scanner.Text()); err != nil {
+			return nil, parseErrs, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, parseErrs, fmt.Errorf("scan input: %w", err)
+	}
+
+	var points []Point
+	if len(parser.data.Series) > 0 {
+		points = parser.data.Series[0].Points
+	}
+	return points, parseErrs, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+// Text Table Output
+// -----------------------------------------------------------------------------
+
+// printTable writes the points of each series of data to w as an aligned text
+// table, one row per point, with the values that are plotted: sorted and
+// transformed as cfg asks, and downsampled to cfg.MaxPoints. X is written as
+// a -bar category or an -xtime timestamp where there is one, and the error
+// bar and colormap columns are added when they are read.
+func printTable(w io.Writer, data Dataset, cfg Config) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	header := []string{"series", firstNonEmpty(data.XLabel, "x"), firstNonEmpty(data.YLabel, "y")}
+	if cfg.ErrorBars {
+		header = append(header, "ylow", "yhigh")
+	}
+	if cfg.Colormap != "" {
+		header = append(header, "z")
+	}
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+
+	layout := timeLayouts[cfg.XTime]
+	if layout == "" {
+		layout = cfg.XTime
+	}
+	for _, s := range data.Series {
+		points := s.Points
+		if cfg.Sort {
+			points = sortByX(points)
+		}
+		if cfg.Transform != "" {
+			points = applyTransform(points, cfg.Transform)
+		}
+		if cfg.MaxPoints > 0 && len(points) > cfg.MaxPoints {
+			points = downsampleLTTB(finitePoints(points), cfg.MaxPoints)
+		}
+
+		for _, pt := range points {
+			x := formatValue(pt.X)
+			switch i := int(pt.X); {
+			case cfg.Bar && pt.X == float64(i) && i >= 0 && i < len(data.Categories):
+				x = data.Categories[i]
+			case cfg.XTime != "" && isFinite(pt.X):
+				x = time.Unix(0, int64(pt.X*1e9)).UTC().Format(layout)
+			}
+			row := []string{s.Label, x, formatValue(pt.Y)}
+			if cfg.ErrorBars {
+				row = append(row, formatValue(pt.Y-pt.ErrLow), formatValue(pt.Y+pt.ErrHigh))
+			}
+			if cfg.Colormap != "" {
+				row = append(row, formatValue(pt.Z))
+			}
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+	}
+	return tw.Flush()
+}
+
+// formatValue formats v with as few digits as identify it, so that values
+// copied from a table read back unchanged.
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
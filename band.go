@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"slices"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+)
+
+// -----------------------------------------------------------------------------
+// Bands
+// -----------------------------------------------------------------------------
+
+// takeBand removes the series read from the two columns of cols, the lower
+// and the upper bound of a band, from series. It returns the other series and
+// the two bounds, in the order of cols.
+func takeBand(series []Series, cols []int) (rest, band []Series, err error) {
+	band = make([]Series, len(cols))
+	for i, col := range cols {
+		j := slices.IndexFunc(series, func(s Series) bool { return s.Column == col })
+		if j < 0 {
+			return nil, nil, fmt.Errorf("-band column %d was not read", col)
+		}
+		band[i] = series[j]
+	}
+	for _, s := range series {
+		if !slices.Contains(cols, s.Column) {
+			rest = append(rest, s)
+		}
+	}
+	return rest, band, nil
+}
+
+// addBand shades the region between the bounds low and high in color c. The
+// bounds are paired by X, and where they cross the band still spans from the
+// smaller value to the larger. The band is broken where either bound is not
+// finite or, as for lines, at gaps in X wider than cfg.GapThreshold. With
+// legend set, the band is also listed in the legend.
+func addBand(p *plot.Plot, low, high Series, c color.Color, legend bool, cfg Config) error {
+	// Pair each low point with the next unused high point at the same X
+	highs := make(map[float64][]float64)
+	for _, pt := range high.Points {
+		highs[pt.X] = append(highs[pt.X], pt.Y)
+	}
+	var lower, upper plotter.XYs
+	for _, pt := range low.Points {
+		q := highs[pt.X]
+		if len(q) == 0 {
+			continue
+		}
+		highs[pt.X] = q[1:]
+		lo, hi := pt.Y, q[0]
+		if isFinite(lo) && isFinite(hi) {
+			lo, hi = math.Min(lo, hi), math.Max(lo, hi)
+		} else {
+			lo, hi = math.NaN(), math.NaN() // Marks a break in the band
+		}
+		lower = append(lower, plotter.XY{X: pt.X, Y: lo})
+		upper = append(upper, plotter.XY{X: pt.X, Y: hi})
+	}
+
+	// Each run of paired points becomes a polygon along the lower bound
+	// and back along the upper one
+	var polys []*plotter.Polygon
+	addRun := func(start, end int) error {
+		if start >= end {
+			return nil
+		}
+		ring := slices.Clone(lower[start:end])
+		for i := end - 1; i >= start; i-- {
+			ring = append(ring, upper[i])
+		}
+		poly, err := plotter.NewPolygon(ring)
+		if err != nil {
+			return fmt.Errorf("create band polygon: %w", err)
+		}
+		poly.Color = c
+		poly.LineStyle.Width = 0
+		polys = append(polys, poly)
+		return nil
+	}
+	start := 0
+	for i, pt := range lower {
+		switch {
+		case !isFinite(pt.X) || !isFinite(pt.Y):
+			if err := addRun(start, i); err != nil {
+				return err
+			}
+			start = i + 1
+		case i > start && cfg.GapThreshold > 0 && math.Abs(pt.X-lower[i-1].X) > cfg.GapThreshold:
+			if err := addRun(start, i); err != nil {
+				return err
+			}
+			start = i
+		}
+	}
+	if err := addRun(start, len(lower)); err != nil {
+		return err
+	}
+	if len(polys) == 0 {
+		return fmt.Errorf("no X values at which both bounds are finite")
+	}
+
+	for _, poly := range polys {
+		p.Add(poly)
+	}
+	if legend {
+		p.Legend.Add(low.Label+" – "+high.Label, polys[0])
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+)
+
+// -----------------------------------------------------------------------------
+// Bar Charts
+// -----------------------------------------------------------------------------
+
+// barGroupFraction is the share of each category's slot, measured across the
+// whole image width, taken up by its bars.
+const barGroupFraction = 0.6
+
+// addBars adds the values of s to p as bars at X = 0, 1, ..., one per
+// category. Series index of n is drawn side by side with the other series in
+// the same category. With legend set, the bars are also listed in the legend.
+func addBars(p *plot.Plot, s Series, index, n int, c color.Color, legend bool, cfg Config) error {
+	var values plotter.Values
+	for _, pt := range s.Points {
+		if !isFinite(pt.Y) {
+			continue
+		}
+		// Skipped lines leave their category empty
+		for len(values) < int(pt.X) {
+			values = append(values, 0)
+		}
+		values = append(values, pt.Y)
+	}
+	if len(values) == 0 {
+		return fmt.Errorf("no finite values")
+	}
+
+	// Share a category's slot of the plot width between the series
+	slot := float64(cfg.Width) / float64(len(values))
+	width := vg.Points(slot * barGroupFraction / float64(n))
+
+	bars, err := plotter.NewBarChart(values, width)
+	if err != nil {
+		return fmt.Errorf("create bar chart: %w", err)
+	}
+	bars.Color = c
+	bars.LineStyle.Width = 0
+	bars.Offset = width * vg.Length(float64(index)-float64(n-1)/2)
+
+	p.Add(bars)
+	if legend {
+		p.Legend.Add(s.Label, bars)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// -----------------------------------------------------------------------------
+// Box Plots
+// -----------------------------------------------------------------------------
+
+// boxWidthFraction is the share of each sample's slot, measured across the
+// whole image width, taken up by its box.
+const boxWidthFraction = 0.5
+
+// addBox adds a box-and-whisker plot of the Y values of s to p at X = index,
+// the position of s among n samples side by side. The box spans the quartiles
+// with a line at the median, the whiskers reach the furthest values within
+// 1.5 interquartile ranges of the box, and values beyond are drawn as points.
+func addBox(p *plot.Plot, s Series, index, n int, c color.Color, cfg Config) error {
+	var values plotter.Values
+	for _, pt := range s.Points {
+		if isFinite(pt.Y) {
+			values = append(values, pt.Y)
+		}
+	}
+	if len(values) == 0 {
+		return fmt.Errorf("no finite values")
+	}
+
+	slot := float64(cfg.Width) / float64(n)
+	box, err := plotter.NewBoxPlot(vg.Points(slot*boxWidthFraction), float64(index), values)
+	if err != nil {
+		return fmt.Errorf("create box plot: %w", err)
+	}
+	box.FillColor = fade(c, 0x40)
+	for _, style := range []*draw.LineStyle{&box.BoxStyle, &box.MedianStyle, &box.WhiskerStyle} {
+		style.Color = c
+		style.Width = vg.Points(cfg.LineWidth)
+	}
+	box.MedianStyle.Width *= 2
+	box.GlyphStyle.Color = c
+
+	p.Add(box)
+	return nil
+}
+
+// sampleColumns returns the field indices read as samples from a -box line of
+// n fields: columns if given, or else every field, since no field is X.
+func sampleColumns(n int, columns []int) []int {
+	if len(columns) > 0 {
+		return columns
+	}
+	cols := make([]int, n)
+	for i := range cols {
+		cols[i] = i
+	}
+	return cols
+}
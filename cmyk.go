@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+// CMYK Output
+// -----------------------------------------------------------------------------
+
+// parseCMYK parses the C/M/Y/K part of a "cmyk:C/M/Y/K" color, four ink
+// percentages from 0 to 100 such as "0/60/100/0".
+func parseCMYK(s string) (color.Color, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("invalid CMYK color %q: expected cmyk:C/M/Y/K percentages", s)
+	}
+	var inks [4]uint8
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || v < 0 || v > 100 {
+			return nil, fmt.Errorf("invalid CMYK color %q: %q is not a percentage from 0 to 100", s, part)
+		}
+		inks[i] = uint8(math.Round(v * 255 / 100))
+	}
+	return color.CMYK{C: inks[0], M: inks[1], Y: inks[2], K: inks[3]}, nil
+}
+
+// cmykColors returns the colors of cfg that were given as CMYK, keyed by the
+// 8-bit RGB values they are drawn with, so that PDF output can write them
+// with their exact inks.
+func cmykColors(cfg Config) map[[3]uint8]color.CMYK {
+	colors := []color.Color{
+		cfg.Colors.Line, cfg.Colors.Scatter, cfg.Colors.Background,
+		cfg.Colors.Foreground, cfg.Colors.RefLine, cfg.Colors.Fill,
+	}
+	for _, st := range cfg.SeriesStyles {
+		colors = append(colors, st.Color)
+	}
+
+	exact := make(map[[3]uint8]color.CMYK)
+	for _, c := range colors {
+		if cmyk, ok := c.(color.CMYK); ok {
+			r, g, b, _ := cmyk.RGBA()
+			exact[[3]uint8{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8)}] = cmyk
+		}
+	}
+	return exact
+}
+
+// cmykPDF writes the PDF of canvas with every color converted to the
+// DeviceCMYK color space, as described at convertPDFToCMYK.
+type cmykPDF struct {
+	canvas io.WriterTo
+	exact  map[[3]uint8]color.CMYK
+}
+
+// WriteTo implements the io.WriterTo interface.
+func (p cmykPDF) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	if _, err := p.canvas.WriteTo(&buf); err != nil {
+		return 0, err
+	}
+	out, err := convertPDFToCMYK(buf.Bytes(), p.exact)
+	if err != nil {
+		return 0, fmt.Errorf("convert PDF to CMYK: %w", err)
+	}
+	n, err := w.Write(out)
+	return int64(n), err
+}
+
+var (
+	xrefEntry    = regexp.MustCompile(`^(\d{10}) \d{5} ([nf])`)
+	contentsRef  = regexp.MustCompile(`/Contents (\d+) 0 R`)
+	streamLength = regexp.MustCompile(`/Length (\d+)`)
+
+	// cmykOperators maps the RGB and gray color operators of a PDF content
+	// stream to their CMYK counterpart and the number of operands they take
+	cmykOperators = map[string]struct {
+		op    string
+		arity int
+	}{"rg": {"k", 3}, "RG": {"K", 3}, "g": {"k", 1}, "G": {"K", 1}}
+)
+
+// convertPDFToCMYK rewrites the page content streams of a PDF written by
+// gonum's vgpdf, whose colors are all RGB or gray, to set them as CMYK
+// instead, and rebuilds the cross-reference table for the changed lengths.
+// RGB values drawn from a color in exact keep its inks; any other color is
+// converted without an ICC profile, taking the black ink from its darkness,
+// which suits the flat colors of a plot but is no print-calibrated match.
+func convertPDFToCMYK(pdf []byte, exact map[[3]uint8]color.CMYK) ([]byte, error) {
+	// The cross-reference table gives the offset of every object
+	xref := bytes.LastIndex(pdf, []byte("\nxref\n"))
+	if xref < 0 {
+		return nil, fmt.Errorf("no cross-reference table")
+	}
+	xref++
+	trailer := bytes.Index(pdf[xref:], []byte("trailer"))
+	if trailer < 0 {
+		return nil, fmt.Errorf("no trailer")
+	}
+	trailer += xref
+	lines := strings.Split(string(pdf[xref:trailer]), "\n")
+	if len(lines) < 2 {
+		return nil, fmt.Errorf("malformed cross-reference table")
+	}
+	offsets := make(map[int]int) // Object number => offset
+	for i, line := range lines[2:] {
+		m := xrefEntry.FindStringSubmatch(line)
+		if m == nil || m[2] == "f" {
+			continue
+		}
+		off, _ := strconv.Atoi(m[1])
+		offsets[i] = off
+	}
+
+	// Each object runs up to the next one, or to the table
+	nums := make([]int, 0, len(offsets))
+	for num := range offsets {
+		nums = append(nums, num)
+	}
+	slices.SortFunc(nums, func(a, b int) int { return offsets[a] - offsets[b] })
+	if len(nums) == 0 {
+		return nil, fmt.Errorf("no objects")
+	}
+	objects := make(map[int][]byte, len(nums))
+	for i, num := range nums {
+		end := xref
+		if i+1 < len(nums) {
+			end = offsets[nums[i+1]]
+		}
+		objects[num] = pdf[offsets[num]:end]
+	}
+
+	contents := make(map[int]bool)
+	for _, obj := range objects {
+		head, _, _ := bytes.Cut(obj, []byte("\nstream\n"))
+		for _, m := range contentsRef.FindAllSubmatch(head, -1) {
+			num, _ := strconv.Atoi(string(m[1]))
+			contents[num] = true
+		}
+	}
+
+	var out bytes.Buffer
+	out.Write(pdf[:offsets[nums[0]]])
+	newOffsets := make(map[int]int, len(nums))
+	for _, num := range nums {
+		obj := objects[num]
+		if contents[num] {
+			var err error
+			if obj, err = convertContentObject(obj, exact); err != nil {
+				return nil, fmt.Errorf("object %d: %w", num, err)
+			}
+		} else if head, rest, ok := bytes.Cut(obj, []byte("\nstream\n")); ok {
+			// Transparency groups blend in the page's color space
+			head = bytes.ReplaceAll(head, []byte("/CS /DeviceRGB"), []byte("/CS /DeviceCMYK"))
+			obj = slices.Concat(head, []byte("\nstream\n"), rest)
+		} else {
+			obj = bytes.ReplaceAll(obj, []byte("/CS /DeviceRGB"), []byte("/CS /DeviceCMYK"))
+		}
+		newOffsets[num] = out.Len()
+		out.Write(obj)
+	}
+
+	size := slices.Max(nums) + 1
+	start := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", size)
+	for num := 1; num < size; num++ {
+		if off, ok := newOffsets[num]; ok {
+			fmt.Fprintf(&out, "%010d 00000 n \n", off)
+		} else {
+			out.WriteString("0000000000 65535 f \n")
+		}
+	}
+	end := bytes.LastIndex(pdf, []byte("startxref"))
+	if end < trailer {
+		return nil, fmt.Errorf("no startxref")
+	}
+	out.Write(pdf[trailer:end])
+	fmt.Fprintf(&out, "startxref\n%d\n%%%%EOF\n", start)
+	return out.Bytes(), nil
+}
+
+// convertContentObject converts the colors in the Flate-compressed content
+// stream of the PDF object obj.
+func convertContentObject(obj []byte, exact map[[3]uint8]color.CMYK) ([]byte, error) {
+	head, rest, ok := bytes.Cut(obj, []byte("\nstream\n"))
+	if !ok {
+		return obj, nil
+	}
+	m := streamLength.FindSubmatchIndex(head)
+	if m == nil {
+		return nil, fmt.Errorf("content stream without a direct /Length")
+	}
+	n, _ := strconv.Atoi(string(head[m[2]:m[3]]))
+	if n > len(rest) {
+		return nil, fmt.Errorf("content stream shorter than its /Length")
+	}
+	data, tail := rest[:n], rest[n:]
+
+	if bytes.Contains(head, []byte("/FlateDecode")) {
+		r, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if data, err = io.ReadAll(r); err != nil {
+			return nil, err
+		}
+	}
+	data = convertColorOperators(data, exact)
+	if bytes.Contains(head, []byte("/FlateDecode")) {
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		zw.Write(data)
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		data = buf.Bytes()
+	}
+
+	head = slices.Concat(head[:m[2]], []byte(strconv.Itoa(len(data))), head[m[3]:])
+	return slices.Concat(head, []byte("\nstream\n"), data, tail), nil
+}
+
+// convertColorOperators replaces the RGB (rg, RG) and gray (g, G) color
+// operators of a PDF content stream with CMYK ones (k, K). String literals
+// are copied unchanged, so text that looks like an operator is left alone.
+func convertColorOperators(content []byte, exact map[[3]uint8]color.CMYK) []byte {
+	type token struct {
+		text []byte
+		word bool // A number or operator, rather than space or a literal
+	}
+	var tokens []token
+	for i := 0; i < len(content); {
+		start := i
+		switch c := content[i]; {
+		case c == ' ' || c == '\n' || c == '\r' || c == '\t':
+			for i < len(content) && strings.IndexByte(" \n\r\t", content[i]) >= 0 {
+				i++
+			}
+			tokens = append(tokens, token{content[start:i], false})
+		case c == '(':
+			// A string literal, which may nest parentheses and escape them
+			depth := 0
+			for ; i < len(content); i++ {
+				if content[i] == '\\' {
+					i++
+					continue
+				}
+				if content[i] == '(' {
+					depth++
+				} else if content[i] == ')' {
+					if depth--; depth == 0 {
+						i++
+						break
+					}
+				}
+			}
+			tokens = append(tokens, token{content[start:min(i, len(content))], false})
+		default:
+			for i < len(content) && strings.IndexByte(" \n\r\t(", content[i]) < 0 {
+				i++
+			}
+			tokens = append(tokens, token{content[start:i], true})
+		}
+	}
+
+	// Each color operator takes the numbers just before it as operands
+	var words []int
+	for i, tok := range tokens {
+		if !tok.word {
+			continue
+		}
+		cmyk, ok := cmykOperators[string(tok.text)]
+		if ok && len(words) >= cmyk.arity {
+			operands := words[len(words)-cmyk.arity:]
+			values := make([]float64, cmyk.arity)
+			valid := true
+			for j, w := range operands {
+				v, err := strconv.ParseFloat(string(tokens[w].text), 64)
+				values[j], valid = v, valid && err == nil
+			}
+			if valid {
+				if cmyk.arity == 1 {
+					values = []float64{values[0], values[0], values[0]}
+				}
+				// The operands and the operator collapse into the first
+				// operand's place
+				for k := operands[0] + 1; k <= i; k++ {
+					tokens[k].text = nil
+				}
+				tokens[operands[0]].text = []byte(rgbToCMYK(values[0], values[1], values[2], exact) + " " + cmyk.op)
+			}
+		}
+		words = append(words, i)
+	}
+
+	var out bytes.Buffer
+	for _, tok := range tokens {
+		out.Write(tok.text)
+	}
+	return out.Bytes()
+}
+
+// rgbToCMYK returns the operands of a CMYK color operator for the RGB color
+// with components from 0 to 1: the inks of the color in exact it was drawn
+// from, if any, or else a conversion taking black from the darkness.
+func rgbToCMYK(r, g, b float64, exact map[[3]uint8]color.CMYK) string {
+	key := [3]uint8{uint8(math.Round(r * 255)), uint8(math.Round(g * 255)), uint8(math.Round(b * 255))}
+	var c, m, y, k float64
+	if cmyk, ok := exact[key]; ok {
+		c, m, y, k = float64(cmyk.C)/255, float64(cmyk.M)/255, float64(cmyk.Y)/255, float64(cmyk.K)/255
+	} else {
+		k = 1 - max(r, g, b)
+		if k < 1 {
+			c, m, y = (1-r-k)/(1-k), (1-g-k)/(1-k), (1-b-k)/(1-k)
+		}
+	}
+	parts := make([]string, 4)
+	for i, v := range []float64{c, m, y, k} {
+		parts[i] = strconv.FormatFloat(math.Round(v*1000)/1000, 'f', -1, 64)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestConvertColorOperators(t *testing.T) {
+	// Gray from colored inks, which the plain conversion would take as black
+	gray := color.CMYK{C: 51, M: 51, Y: 51, K: 0} // cmyk:20/20/20/0
+	r, g, b, _ := gray.RGBA()
+	exact := map[[3]uint8]color.CMYK{{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8)}: gray}
+	grayRGB := fmt.Sprintf("%v %v %v", float64(r>>8)/255, float64(g>>8)/255, float64(b>>8)/255)
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "fill rgb", in: "1 0 0 rg", want: "0 1 1 0 k"},
+		{name: "stroke rgb", in: "0 0 1 RG", want: "1 1 0 0 K"},
+		{name: "fill gray", in: "0.5 g", want: "0 0 0 0.5 k"},
+		{name: "stroke gray", in: "0 G", want: "0 0 0 1 K"},
+		{name: "white", in: "1 1 1 rg", want: "0 0 0 0 k"},
+		{name: "surrounding operators", in: "q\n1 0 0 RG\n0 0 m 1 1 l S\nQ", want: "q\n0 1 1 0 K\n0 0 m 1 1 l S\nQ"},
+		{name: "string literal", in: "BT (1 0 0 rg) Tj ET", want: "BT (1 0 0 rg) Tj ET"},
+		{name: "nested string literal", in: "BT (a (0 g) \\) 1 g) Tj ET 1 g", want: "BT (a (0 g) \\) 1 g) Tj ET 0 0 0 0 k"},
+		{name: "too few operands", in: "0 0 rg", want: "0 0 rg"},
+		{name: "non-numeric operands", in: "/a /b /c rg", want: "/a /b /c rg"},
+		{name: "exact inks", in: grayRGB + " rg", want: "0.2 0.2 0.2 0 k"},
+		{name: "exact inks stroke", in: grayRGB + " RG", want: "0.2 0.2 0.2 0 K"},
+		{name: "exact inks gray", in: "0.8 g", want: "0.2 0.2 0.2 0 k"},
+		{name: "other gray", in: "0.6 g", want: "0 0 0 0.4 k"},
+	}
+	for _, tt := range tests {
+		got := string(convertColorOperators([]byte(tt.in), exact))
+		if got != tt.want {
+			t.Errorf("%s: convertColorOperators(%q) = %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCMYKPDFCrossReference(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Format = "pdf"
+	cfg.CMYK = true
+	cfg.Colors.Line = color.CMYK{C: 0, M: 153, Y: 255, K: 0}
+	p, err := renderPoints([]Point{{X: 0, Y: 1}, {X: 1, Y: 3}, {X: 2, Y: 2}}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := writeFigure(&buf, "pdf", cfg, p.Draw); err != nil {
+		t.Fatal(err)
+	}
+	pdf := buf.Bytes()
+
+	m := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(pdf)
+	if m == nil {
+		t.Fatal("PDF does not end in startxref")
+	}
+	start, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(pdf[start:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point at the cross-reference table", start)
+	}
+
+	entries := regexp.MustCompile(`(\d{10}) \d{5} ([nf]) \n`).FindAllSubmatch(pdf[start:], -1)
+	if len(entries) < 2 {
+		t.Fatalf("cross-reference table has %d entries", len(entries))
+	}
+	for num, e := range entries {
+		if string(e[2]) == "f" {
+			continue
+		}
+		off, _ := strconv.Atoi(string(e[1]))
+		want := fmt.Sprintf("%d 0 obj", num)
+		if off >= len(pdf) || !bytes.HasPrefix(pdf[off:], []byte(want)) {
+			t.Errorf("offset %d of object %d does not point at %q", off, num, want)
+		}
+	}
+}
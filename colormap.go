@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	plotpalette "gonum.org/v1/plot/palette"
+	"gonum.org/v1/plot/palette/moreland"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// -----------------------------------------------------------------------------
+// Color Maps
+// -----------------------------------------------------------------------------
+
+var (
+	// Control colors of matplotlib's perceptually uniform color maps, in
+	// order of increasing luminance
+	viridisColors = []color.Color{
+		color.RGBA{0x44, 0x01, 0x54, 0xff}, color.RGBA{0x47, 0x2d, 0x7b, 0xff},
+		color.RGBA{0x3b, 0x52, 0x8b, 0xff}, color.RGBA{0x2c, 0x72, 0x8e, 0xff},
+		color.RGBA{0x21, 0x91, 0x8c, 0xff}, color.RGBA{0x28, 0xae, 0x80, 0xff},
+		color.RGBA{0x5e, 0xc9, 0x62, 0xff}, color.RGBA{0xad, 0xdc, 0x30, 0xff},
+		color.RGBA{0xfd, 0xe7, 0x25, 0xff},
+	}
+	plasmaColors = []color.Color{
+		color.RGBA{0x0d, 0x08, 0x87, 0xff}, color.RGBA{0x6a, 0x00, 0xa8, 0xff},
+		color.RGBA{0xb1, 0x2a, 0x90, 0xff}, color.RGBA{0xe1, 0x64, 0x62, 0xff},
+		color.RGBA{0xfc, 0xa6, 0x36, 0xff}, color.RGBA{0xf0, 0xf9, 0x21, 0xff},
+	}
+
+	// Color maps selected with -colormap
+	colormaps = map[string]func() (plotpalette.ColorMap, error){
+		"viridis":   func() (plotpalette.ColorMap, error) { return moreland.NewLuminance(viridisColors) },
+		"plasma":    func() (plotpalette.ColorMap, error) { return moreland.NewLuminance(plasmaColors) },
+		"kindlmann": func() (plotpalette.ColorMap, error) { return moreland.Kindlmann(), nil },
+		"blackbody": func() (plotpalette.ColorMap, error) { return moreland.BlackBody(), nil },
+		"bluered":   func() (plotpalette.ColorMap, error) { return moreland.SmoothBlueRed(), nil },
+	}
+)
+
+// newColorMap returns the color map named by cfg.Colormap, spanning the
+// range of the finite Z values in series, or nil without -colormap.
+func newColorMap(series []Series, cfg Config) (plotpalette.ColorMap, error) {
+	if cfg.Colormap == "" {
+		return nil, nil
+	}
+	cmap, err := colormaps[cfg.Colormap]()
+	if err != nil {
+		return nil, fmt.Errorf("colormap %s: %w", cfg.Colormap, err)
+	}
+
+	zmin, zmax := math.Inf(1), math.Inf(-1)
+	for _, s := range series {
+		for _, pt := range s.Points {
+			if isFinite(pt.Z) {
+				zmin = math.Min(zmin, pt.Z)
+				zmax = math.Max(zmax, pt.Z)
+			}
+		}
+	}
+	switch {
+	case zmin > zmax:
+		zmin, zmax = 0, 1 // No finite values
+	case zmin == zmax:
+		zmin, zmax = zmin-0.5, zmax+0.5
+	}
+	cmap.SetMin(zmin)
+	cmap.SetMax(zmax)
+	return cmap, nil
+}
+
+// colorByZ colors each point of scatter by the Z value of the matching finite
+// point using cmap. Points whose Z is outside the map keep the scatter color.
+func colorByZ(scatter *plotter.Scatter, points []Point, cmap plotpalette.ColorMap) {
+	points = finitePoints(points) // As plotted by createPlotters
+	base := scatter.GlyphStyle
+	scatter.GlyphStyleFunc = func(i int) draw.GlyphStyle {
+		style := base
+		if c, err := cmap.At(points[i].Z); err == nil {
+			style.Color = c
+		}
+		return style
+	}
+}
+
+// splitColorValue separates the color value from the Y value of a line read
+// with -colormap, "y z".
+func splitColorValue(values []float64) (ys []float64, z float64, err error) {
+	if len(values) != 2 {
+		return nil, 0, fmt.Errorf("expected Y and a color value, got %d values", len(values))
+	}
+	return values[:1], values[1], nil
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+// Config File and Environment
+// -----------------------------------------------------------------------------
+//
+// Settings are taken, from lowest to highest precedence, from the built-in
+// defaults, the config file, PLOTVIEW_* environment variables, and the
+// command line.
+
+// defaultConfigFile is the config file read from the home directory when no
+// -config flag is given.
+const defaultConfigFile = ".plotview.toml"
+
+// applyConfigFile sets the flags of fs from the config file named by a -config
+// flag in args, or else from ~/.plotview.toml if it exists. It runs before the
+// command line is parsed, so flags given there override the file.
+func applyConfigFile(fs *flag.FlagSet, args []string) error {
+	path, explicit := configPath(fs, args)
+	if path == "" {
+		return nil
+	}
+
+	settings, err := loadConfig(path)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, s := range settings {
+		if s.key == "config" || fs.Lookup(s.key) == nil {
+			return fmt.Errorf("%s:%d: unknown setting %q", path, s.line, s.key)
+		}
+		if err := fs.Set(s.key, s.value); err != nil {
+			return fmt.Errorf("%s:%d: %s: %w", path, s.line, s.key, err)
+		}
+	}
+	return nil
+}
+
+// envFlags lists the environment variables consulted for defaults and the
+// flags they set.
+var envFlags = []struct{ env, flag string }{
+	{"PLOTVIEW_WIDTH", "w"},
+	{"PLOTVIEW_HEIGHT", "h"},
+	{"PLOTVIEW_SCALE", "s"},
+	{"PLOTVIEW_THEME", "theme"},
+}
+
+// applyEnv sets the flags of fs from the PLOTVIEW_* environment variables
+// that are set and non-empty. It runs after applyConfigFile and before the
+// command line is parsed, so it overrides the config file and flags override
+// it.
+func applyEnv(fs *flag.FlagSet) error {
+	for _, e := range envFlags {
+		value := os.Getenv(e.env)
+		if value == "" {
+			continue
+		}
+		if err := fs.Set(e.flag, value); err != nil {
+			return fmt.Errorf("%s=%q: %w", e.env, value, err)
+		}
+	}
+	return nil
+}
+
+// configPath returns the config file to read and whether it was named by a
+// -config flag in args. The flags of fs are scanned as fs.Parse would, so the
+// value of an earlier flag, as in "-w 800 -config f.toml", does not end them.
+func configPath(fs *flag.FlagSet, args []string) (string, bool) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "-" || arg == "--" || !strings.HasPrefix(arg, "-") {
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if name == "config" {
+			if hasValue {
+				return value, true
+			}
+			if i+1 < len(args) {
+				return args[i+1], true
+			}
+			break
+		}
+		// Flags other than booleans take the next argument as their value,
+		// unless it was attached with '='
+		if f := fs.Lookup(name); f != nil && !hasValue && !isBoolFlag(f) {
+			i++
+		}
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", false
+	}
+	return filepath.Join(home, defaultConfigFile), false
+}
+
+// isBoolFlag reports whether f is a boolean flag, which can be given without
+// a value.
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// setting is one "key = value" line of a config file.
+type setting struct {
+	key, value string
+	line       int
+}
+
+// loadConfig reads the settings in a config file. The file uses a flat subset
+// of TOML: each line is "key = value", where the key is a flag name and the
+// value is a quoted string, a number, a boolean, or an array of those, which
+// is passed to the flag as a comma-separated list. Comments start with '#'.
+func loadConfig(path string) ([]setting, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open config file: %w", err)
+	}
+	defer file.Close()
+
+	var settings []setting
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, raw, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected key = value, got %q", path, n, line)
+		}
+		value, err := parseConfigValue(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		settings = append(settings, setting{key: strings.TrimSpace(key), value: value, line: n})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+	return settings, nil
+}
+
+// parseConfigValue converts a TOML value to the text of a flag value. Arrays
+// become comma-separated lists; a trailing comment is ignored.
+func parseConfigValue(raw string) (string, error) {
+	if inner, ok := strings.CutPrefix(raw, "["); ok {
+		end := strings.LastIndex(inner, "]")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated array %q", raw)
+		}
+		// Quotes around the items are stripped while splitting, and TOML
+		// allows a comma after the last item
+		items := splitFields(inner[:end], ',')
+		if endsInEmptyField(items) {
+			items = items[:len(items)-1]
+		}
+		return strings.Join(items, ","), nil
+	}
+
+	if strings.HasPrefix(raw, `"`) {
+		// Find the closing quote, skipping escaped ones
+		end := 1
+		for end < len(raw) && raw[end] != '"' {
+			if raw[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(raw) {
+			return "", fmt.Errorf("unterminated string %s", raw)
+		}
+		s, err := strconv.Unquote(raw[:end+1])
+		if err != nil {
+			return "", fmt.Errorf("invalid string %s", raw[:end+1])
+		}
+		return s, nil
+	}
+
+	// Bare numbers and booleans
+	if i := strings.Index(raw, "#"); i >= 0 {
+		raw = strings.TrimSpace(raw[:i])
+	}
+	if raw == "" {
+		return "", fmt.Errorf("missing value")
+	}
+	return raw, nil
+}
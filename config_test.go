@@ -0,0 +1,185 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestFlags returns a flag set with every command-line flag, including
+// -config, writing into cfg.
+func newTestFlags(cfg *Config) *flag.FlagSet {
+	fs := flag.NewFlagSet("plotview", flag.ContinueOnError)
+	defineFlags(fs, cfg)
+	fs.String("config", "", "")
+	return fs
+}
+
+func TestConfigPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	defaultPath := filepath.Join(home, defaultConfigFile)
+
+	tests := []struct {
+		args         []string
+		want         string
+		wantExplicit bool
+	}{
+		{args: []string{"-config", "f.toml", "data.dat"}, want: "f.toml", wantExplicit: true},
+		{args: []string{"--config", "f.toml", "data.dat"}, want: "f.toml", wantExplicit: true},
+		{args: []string{"-config=f.toml", "data.dat"}, want: "f.toml", wantExplicit: true},
+		{args: []string{"-w", "800", "-config", "f.toml", "data.dat"}, want: "f.toml", wantExplicit: true},
+		{args: []string{"-protocol", "none", "-config", "f.toml", "data.dat"}, want: "f.toml", wantExplicit: true},
+		{args: []string{"-protocol=none", "-config", "f.toml", "data.dat"}, want: "f.toml", wantExplicit: true},
+		{args: []string{"-logx", "-config", "f.toml", "data.dat"}, want: "f.toml", wantExplicit: true},
+		{args: []string{"-logx=true", "-w", "800", "-config=f.toml", "data.dat"}, want: "f.toml", wantExplicit: true},
+		{args: []string{"-w", "800", "data.dat"}, want: defaultPath},
+		// Flags end at the first input, as for flag.Parse
+		{args: []string{"data.dat", "-config", "f.toml"}, want: defaultPath},
+		{args: []string{"--", "-config", "f.toml"}, want: defaultPath},
+		{args: []string{"-", "-config", "f.toml"}, want: defaultPath},
+	}
+	for _, tt := range tests {
+		var cfg Config
+		got, explicit := configPath(newTestFlags(&cfg), tt.args)
+		if got != tt.want || explicit != tt.wantExplicit {
+			t.Errorf("configPath(%q) = %q, %v, want %q, %v", tt.args, got, explicit, tt.want, tt.wantExplicit)
+		}
+	}
+}
+
+func TestApplyConfigFile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.toml")
+	if err := os.WriteFile(good, []byte("title = \"From file\"\nw = 640\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bad := filepath.Join(dir, "bad.toml")
+	if err := os.WriteFile(bad, []byte("not a setting\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	orderings := []struct {
+		name string
+		args func(path string) []string
+	}{
+		{"first", func(path string) []string { return []string{"-config", path, "-w", "800", "data.dat"} }},
+		{"attached", func(path string) []string { return []string{"-protocol", "none", "-config=" + path, "data.dat"} }},
+		{"after a flag value", func(path string) []string { return []string{"-w", "800", "-config", path, "data.dat"} }},
+		{"after a string value", func(path string) []string { return []string{"-protocol", "none", "-config", path, "data.dat"} }},
+		{"after a bool flag", func(path string) []string { return []string{"-logx", "-config", path, "data.dat"} }},
+	}
+	for _, o := range orderings {
+		t.Run(o.name, func(t *testing.T) {
+			var cfg Config
+			if err := applyConfigFile(newTestFlags(&cfg), o.args(good)); err != nil {
+				t.Fatalf("applyConfigFile: %v", err)
+			}
+			if cfg.Title != "From file" {
+				t.Errorf("Title = %q after the config file, want %q", cfg.Title, "From file")
+			}
+
+			cfg = Config{}
+			if err := applyConfigFile(newTestFlags(&cfg), o.args(bad)); err == nil {
+				t.Errorf("applyConfigFile of a malformed file succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestApplyConfigFileFlagOverrides(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "f.toml")
+	if err := os.WriteFile(path, []byte("w = 640\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var cfg Config
+	fs := newTestFlags(&cfg)
+	args := []string{"-w", "800", "-config", path, "data.dat"}
+	if err := applyConfigFile(fs, args); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Width != 800 {
+		t.Errorf("Width = %d, want 800 from the command line over the file's 640", cfg.Width)
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	t.Setenv("PLOTVIEW_WIDTH", "640")
+	t.Setenv("PLOTVIEW_HEIGHT", "")
+	t.Setenv("PLOTVIEW_SCALE", "1.5")
+	t.Setenv("PLOTVIEW_THEME", "dark")
+
+	var cfg Config
+	if err := applyEnv(newTestFlags(&cfg)); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Width != 640 {
+		t.Errorf("Width = %d, want 640 from PLOTVIEW_WIDTH", cfg.Width)
+	}
+	if cfg.Height != DefaultConfig().Height {
+		t.Errorf("Height = %d, want the default %d for an empty PLOTVIEW_HEIGHT", cfg.Height, DefaultConfig().Height)
+	}
+	if cfg.Scale != 1.5 {
+		t.Errorf("Scale = %v, want 1.5 from PLOTVIEW_SCALE", cfg.Scale)
+	}
+	if cfg.Theme != "dark" {
+		t.Errorf("Theme = %q, want %q from PLOTVIEW_THEME", cfg.Theme, "dark")
+	}
+}
+
+func TestApplyEnvBadValue(t *testing.T) {
+	t.Setenv("PLOTVIEW_WIDTH", "wide")
+
+	var cfg Config
+	if err := applyEnv(newTestFlags(&cfg)); err == nil {
+		t.Errorf("applyEnv with PLOTVIEW_WIDTH=wide succeeded, want an error")
+	}
+}
+
+func TestApplyEnvFlagOverrides(t *testing.T) {
+	t.Setenv("PLOTVIEW_WIDTH", "640")
+	t.Setenv("PLOTVIEW_THEME", "dark")
+
+	var cfg Config
+	fs := newTestFlags(&cfg)
+	if err := applyEnv(fs); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse([]string{"-w", "800", "data.dat"}); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Width != 800 {
+		t.Errorf("Width = %d, want 800 from the command line over PLOTVIEW_WIDTH", cfg.Width)
+	}
+	if cfg.Theme != "dark" {
+		t.Errorf("Theme = %q, want %q from PLOTVIEW_THEME, which no flag overrides", cfg.Theme, "dark")
+	}
+}
+
+func TestApplyEnvOverridesConfigFile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("PLOTVIEW_WIDTH", "640")
+	path := filepath.Join(t.TempDir(), "f.toml")
+	if err := os.WriteFile(path, []byte("w = 500\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var cfg Config
+	fs := newTestFlags(&cfg)
+	if err := applyConfigFile(fs, []string{"-config", path}); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyEnv(fs); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Width != 640 {
+		t.Errorf("Width = %d, want 640 from PLOTVIEW_WIDTH over the file's 500", cfg.Width)
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+)
+
+// -----------------------------------------------------------------------------
+// Curve Fitting
+// -----------------------------------------------------------------------------
+
+// fitSamples is the number of points along a fitted curve other than a line.
+const fitSamples = 200
+
+// parseFit splits a -fit value into the model name and, for "poly:N", the
+// polynomial degree.
+func parseFit(kind string) (model string, degree int, err error) {
+	model, arg, hasArg := strings.Cut(kind, ":")
+	switch {
+	case (model == "linear" || model == "exp" || model == "power") && !hasArg:
+		return model, 1, nil
+	case model == "poly" && hasArg:
+		degree, err = strconv.Atoi(arg)
+		if err != nil || degree < 1 {
+			return "", 0, fmt.Errorf("polynomial degree must be a positive integer, got %q", arg)
+		}
+		return model, degree, nil
+	}
+	return "", 0, fmt.Errorf("unsupported fit %q", kind)
+}
+
+// addFit fits cfg.Fit to the series, logs the fitted parameters, and adds the
+// fitted curve to p as a dashed line. With legend set, the curve is also
+// listed in the plot legend.
+func addFit(p *plot.Plot, s Series, c color.Color, legend bool, cfg Config) error {
+	curve, err := fitSeries(s, cfg)
+	if err != nil {
+		return err
+	}
+
+	line, err := plotter.NewLine(toXYs(curve))
+	if err != nil {
+		return fmt.Errorf("create fit line: %w", err)
+	}
+	line.Color = c
+	line.Width = vg.Points(cfg.LineWidth)
+	line.Dashes = []vg.Length{vg.Points(6), vg.Points(4)}
+
+	p.Add(line)
+	if legend {
+		p.Legend.Add(s.Label+" ("+cfg.Fit+" fit)", line)
+	}
+	return nil
+}
+
+// fitSeries fits the model named by cfg.Fit to the points of s, logs the
+// fitted parameters, and returns points along the fitted curve.
+func fitSeries(s Series, cfg Config) ([]Point, error) {
+	kind := cfg.Fit
+	s.Points = finitePoints(s.Points)
+	if len(s.Points) < 2 {
+		return nil, fmt.Errorf("need at least 2 points, got %d", len(s.Points))
+	}
+	xmin, xmax := xRange(s.Points)
+
+	model, degree, err := parseFit(kind)
+	if err != nil {
+		return nil, err
+	}
+	switch model {
+	case "linear":
+		slope, intercept, r2 := linearFit(s.Points)
+		if math.IsNaN(slope) {
+			return nil, fmt.Errorf("all X values are equal")
+		}
+		logInfo(cfg, "Linear fit for %s: slope=%g intercept=%g R²=%g", s.Label, slope, intercept, r2)
+		return []Point{
+			{X: xmin, Y: slope*xmin + intercept},
+			{X: xmax, Y: slope*xmax + intercept},
+		}, nil
+
+	case "poly":
+		coeffs, err := polyFit(s.Points, degree)
+		if err != nil {
+			return nil, err
+		}
+		eval := func(x float64) float64 { return polyEval(coeffs, x) }
+		terms := make([]string, len(coeffs))
+		for i, c := range coeffs {
+			terms[i] = fmt.Sprintf("c%d=%g", i, c)
+		}
+		logInfo(cfg, "Degree %d polynomial fit for %s: %s R²=%g",
+			degree, s.Label, strings.Join(terms, " "), rSquared(s.Points, eval))
+		return sampleCurve(eval, xmin, xmax), nil
+
+	case "exp":
+		// y = a·e^(bx) is the line ln y = ln a + bx
+		logged := make([]Point, len(s.Points))
+		for i, pt := range s.Points {
+			if pt.Y <= 0 {
+				return nil, fmt.Errorf("exponential fit needs Y > 0, got %g at X=%g", pt.Y, pt.X)
+			}
+			logged[i] = Point{X: pt.X, Y: math.Log(pt.Y)}
+		}
+		b, lnA, _ := linearFit(logged)
+		if math.IsNaN(b) {
+			return nil, fmt.Errorf("all X values are equal")
+		}
+		a := math.Exp(lnA)
+		eval := func(x float64) float64 { return a * math.Exp(b*x) }
+		logInfo(cfg, "Exponential fit for %s: y = a·e^(bx) with a=%g b=%g R²=%g", s.Label, a, b, rSquared(s.Points, eval))
+		return sampleCurve(eval, xmin, xmax), nil
+
+	case "power":
+		// y = a·x^b is the line ln y = ln a + b ln x
+		logged := make([]Point, len(s.Points))
+		for i, pt := range s.Points {
+			if pt.X <= 0 || pt.Y <= 0 {
+				return nil, fmt.Errorf("power-law fit needs X > 0 and Y > 0, got (%g, %g)", pt.X, pt.Y)
+			}
+			logged[i] = Point{X: math.Log(pt.X), Y: math.Log(pt.Y)}
+		}
+		b, lnA, _ := linearFit(logged)
+		if math.IsNaN(b) {
+			return nil, fmt.Errorf("all X values are equal")
+		}
+		a := math.Exp(lnA)
+		eval := func(x float64) float64 { return a * math.Pow(x, b) }
+		logInfo(cfg, "Power-law fit for %s: y = a·x^b with a=%g b=%g R²=%g", s.Label, a, b, rSquared(s.Points, eval))
+		return sampleCurve(eval, xmin, xmax), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported fit %q", kind)
+	}
+}
+
+// linearFit computes the least-squares line y = slope*x + intercept through
+// points and its coefficient of determination R². The slope is NaN when all
+// X values are equal.
+func linearFit(points []Point) (slope, intercept, r2 float64) {
+	n := float64(len(points))
+	var sumX, sumY float64
+	for _, pt := range points {
+		sumX += pt.X
+		sumY += pt.Y
+	}
+	meanX, meanY := sumX/n, sumY/n
+
+	var sxx, sxy, syy float64
+	for _, pt := range points {
+		dx, dy := pt.X-meanX, pt.Y-meanY
+		sxx += dx * dx
+		sxy += dx * dy
+		syy += dy * dy
+	}
+	if sxx == 0 {
+		return math.NaN(), math.NaN(), math.NaN()
+	}
+
+	slope = sxy / sxx
+	intercept = meanY - slope*meanX
+	r2 = 1.0
+	if syy != 0 {
+		r2 = sxy * sxy / (sxx * syy)
+	}
+	return slope, intercept, r2
+}
+
+// polyFit computes the least-squares polynomial of the given degree through
+// points, returning its coefficients from the constant term up. The system is
+// solved by Householder QR decomposition of the Vandermonde matrix, which is
+// far better conditioned than the normal equations at higher degrees.
+func polyFit(points []Point, degree int) ([]float64, error) {
+	n, m := len(points), degree+1
+	if n < m {
+		return nil, fmt.Errorf("degree %d fit needs at least %d points, got %d", degree, m, n)
+	}
+
+	// a holds the Vandermonde matrix by columns, b the Y values
+	a := make([][]float64, m)
+	for j := range a {
+		a[j] = make([]float64, n)
+		for i, pt := range points {
+			a[j][i] = math.Pow(pt.X, float64(j))
+		}
+	}
+	b := make([]float64, n)
+	for i, pt := range points {
+		b[i] = pt.Y
+	}
+
+	// Reduce a to upper triangular form, applying the same reflections to b
+	for k := 0; k < m; k++ {
+		var norm float64
+		for i := k; i < n; i++ {
+			norm = math.Hypot(norm, a[k][i])
+		}
+		if norm == 0 {
+			return nil, fmt.Errorf("too few distinct X values for a degree %d fit", degree)
+		}
+		if a[k][k] > 0 {
+			norm = -norm
+		}
+		a[k][k] -= norm // a[k][k:] is now the reflection vector v
+		vv := -norm * a[k][k]
+		reflect := func(col []float64) {
+			var dot float64
+			for i := k; i < n; i++ {
+				dot += a[k][i] * col[i]
+			}
+			f := dot / vv
+			for i := k; i < n; i++ {
+				col[i] -= f * a[k][i]
+			}
+		}
+		for j := k + 1; j < m; j++ {
+			reflect(a[j])
+		}
+		reflect(b)
+		a[k][k] = norm // The diagonal of R
+	}
+
+	// Back-substitute through R
+	coeffs := make([]float64, m)
+	for k := m - 1; k >= 0; k-- {
+		sum := b[k]
+		for j := k + 1; j < m; j++ {
+			sum -= a[j][k] * coeffs[j]
+		}
+		coeffs[k] = sum / a[k][k]
+	}
+	for _, c := range coeffs {
+		if !isFinite(c) {
+			return nil, fmt.Errorf("degree %d fit is numerically singular", degree)
+		}
+	}
+	return coeffs, nil
+}
+
+// polyEval evaluates the polynomial with the given coefficients, from the
+// constant term up, at x.
+func polyEval(coeffs []float64, x float64) float64 {
+	var y float64
+	for i := len(coeffs) - 1; i >= 0; i-- {
+		y = y*x + coeffs[i]
+	}
+	return y
+}
+
+// rSquared returns the coefficient of determination of the model f for points.
+func rSquared(points []Point, f func(float64) float64) float64 {
+	var mean float64
+	for _, pt := range points {
+		mean += pt.Y
+	}
+	mean /= float64(len(points))
+
+	var ssRes, ssTot float64
+	for _, pt := range points {
+		ssRes += (pt.Y - f(pt.X)) * (pt.Y - f(pt.X))
+		ssTot += (pt.Y - mean) * (pt.Y - mean)
+	}
+	if ssTot == 0 {
+		return 1.0
+	}
+	return 1 - ssRes/ssTot
+}
+
+// sampleCurve returns fitSamples points of f evenly spaced from xmin to xmax.
+func sampleCurve(f func(float64) float64, xmin, xmax float64) []Point {
+	curve := make([]Point, fitSamples)
+	for i := range curve {
+		x := xmin + (xmax-xmin)*float64(i)/float64(fitSamples-1)
+		curve[i] = Point{X: x, Y: f(x)}
+	}
+	return curve
+}
+
+// xRange returns the smallest and largest X value of points.
+func xRange(points []Point) (xmin, xmax float64) {
+	xmin, xmax = math.Inf(1), math.Inf(-1)
+	for _, pt := range points {
+		xmin = math.Min(xmin, pt.X)
+		xmax = math.Max(xmax, pt.X)
+	}
+	return xmin, xmax
+}
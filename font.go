@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/font"
+	"gonum.org/v1/plot/plotter"
+)
+
+// -----------------------------------------------------------------------------
+// Custom Fonts
+// -----------------------------------------------------------------------------
+
+// loadFont registers the TrueType or OpenType font in filename with gonum's
+// font cache and makes it the default for all plot text. It must run before
+// any plot is built. The font is named after its family, so that vector
+// output refers to it by a name viewers can find.
+func loadFont(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("read font: %w", err)
+	}
+	face, err := opentype.Parse(data)
+	if err != nil {
+		return fmt.Errorf("parse font %q: %w", filename, err)
+	}
+
+	name, err := face.Name(nil, sfnt.NameIDFamily)
+	if err != nil || name == "" {
+		name = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	}
+	fnt := font.Font{Typeface: font.Typeface(name)}
+	font.DefaultCache.Add(font.Collection{{Font: fnt, Face: face}})
+	plot.DefaultFont = fnt
+	plotter.DefaultFont = fnt
+	return nil
+}
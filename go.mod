@@ -2,6 +2,13 @@ module PlotView
 
 go 1.23.3
 
+require (
+	github.com/mattn/go-sixel v0.0.5
+	golang.org/x/image v0.22.0
+	golang.org/x/term v0.27.0
+	gonum.org/v1/plot v0.15.0
+)
+
 require (
 	git.sr.ht/~sbinet/gg v0.6.0 // indirect
 	github.com/ajstarks/svgo v0.0.0-20211024235047-1546f124cd8b // indirect
@@ -10,10 +17,8 @@ require (
 	github.com/go-latex/latex v0.0.0-20240709081214-31cef3c7570e // indirect
 	github.com/go-pdf/fpdf v0.9.0 // indirect
 	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
-	github.com/mattn/go-sixel v0.0.5 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/soniakeys/quant v1.0.0 // indirect
-	golang.org/x/image v0.22.0 // indirect
+	golang.org/x/sys v0.28.0 // indirect
 	golang.org/x/text v0.20.0 // indirect
-	gonum.org/v1/plot v0.15.0 // indirect
 )
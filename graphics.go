@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/term"
+)
+
+// -----------------------------------------------------------------------------
+// Terminal Graphics Protocols
+// -----------------------------------------------------------------------------
+
+const (
+	// kittyChunkSize is the largest base64 payload allowed in one kitty
+	// graphics escape sequence.
+	kittyChunkSize = 4096
+
+	// brailleReservedRows is the number of terminal rows left free below a
+	// braille rendering for the range line and the shell prompt.
+	brailleReservedRows = 3
+)
+
+// displayImage shows the plot in the terminal using the protocol selected by
+// cfg.Protocol. With "auto", the kitty or iTerm2 protocol is used when the
+// terminal is known to support it, then SIXEL, and finally a Unicode braille
+// rendering of data when stdout is a terminal without graphics support, unless
+// the plot's mode or axes are ones the braille rendering cannot show.
+// The graphics protocols show img, the rendered plot, which is nil when only
+// vector formats were saved; filename names a saved PNG of it, if any, that
+// can be passed on without encoding the image again.
+func displayImage(filename string, img image.Image, data Dataset, cfg Config) error {
+	cfg = resolveProtocol(cfg)
+	protocol := cfg.Protocol
+
+	// Vector formats are not rendered into an image for terminal display
+	if protocol != "text" && img == nil {
+		return nil
+	}
+
+	switch protocol {
+	case "text":
+		// The braille rendering draws the raw series on linear axes, which
+		// would misrepresent these plots
+		if cfg.Hist || cfg.Bar || cfg.Box || cfg.Transpose || cfg.LogX || cfg.LogY || cfg.XReverse || cfg.YReverse {
+			logInfo(cfg, "Text preview skipped: it cannot show -hist, -bar, -box, -transpose, log, or reversed axes")
+			return nil
+		}
+		cols, rows := terminalSize()
+		fmt.Print(renderBrailleSeries(data.Series, cols, rows-brailleReservedRows, cfg))
+	case "iterm":
+		if err := displayITerm(filename, img, cfg); err != nil {
+			return fmt.Errorf("iTerm2 inline image: %w", err)
+		}
+	case "kitty":
+		if err := displayKitty(filename, img, cfg); err != nil {
+			return fmt.Errorf("kitty graphics: %w", err)
+		}
+	case "sixel":
+		if err := displaySixel(img, cfg); err != nil {
+			return fmt.Errorf("SIXEL: %w", err)
+		}
+	}
+	return nil
+}
+
+// resolveProtocol returns cfg with Protocol set to the terminal graphics
+// protocol that displayImage uses, detecting one for "auto" as described
+// there. SIXEL counts only when sixelEnabled, and once chosen cfg.Sixel
+// becomes "always", so that support is not queried again.
+func resolveProtocol(cfg Config) Config {
+	switch cfg.Protocol {
+	case "auto":
+		switch {
+		case isKittyTerminal():
+			cfg.Protocol = "kitty"
+		case isITermTerminal():
+			cfg.Protocol = "iterm"
+		case sixelEnabled(cfg):
+			cfg.Protocol = "sixel"
+			cfg.Sixel = "always" // Support was just detected
+		case cfg.Sixel == "auto" && term.IsTerminal(int(os.Stdout.Fd())):
+			cfg.Protocol = "text"
+		default:
+			cfg.Protocol = "none"
+		}
+	case "sixel":
+		if !sixelEnabled(cfg) {
+			cfg.Protocol = "none"
+		}
+		cfg.Sixel = "always"
+	}
+	return cfg
+}
+
+// displayKitty displays the image using the kitty graphics protocol,
+// transmitting it as base64-encoded PNG data split across escape sequences.
+func displayKitty(filename string, img image.Image, cfg Config) error {
+	data, err := scaledPNG(filename, img, cfg)
+	if err != nil {
+		return err
+	}
+	return writeKitty(os.Stdout, data)
+}
+
+// writeKitty writes PNG data to w as a kitty graphics "transmit and display"
+// command. Each chunk carries m=1 except the last, which carries m=0.
+func writeKitty(w io.Writer, data []byte) error {
+	payload := base64.StdEncoding.EncodeToString(data)
+	for first := true; first || payload != ""; first = false {
+		chunk := payload[:min(kittyChunkSize, len(payload))]
+		payload = payload[len(chunk):]
+
+		more := 0
+		if payload != "" {
+			more = 1
+		}
+		control := fmt.Sprintf("m=%d", more)
+		if first {
+			control = "f=100,a=T," + control
+		}
+		if _, err := fmt.Fprintf(w, "\x1b_G%s;%s\x1b\\", control, chunk); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// clearScreen erases the terminal and any kitty graphics on it, and moves the
+// cursor home so the next image replaces the previous one.
+func clearScreen() {
+	if isKittyTerminal() {
+		fmt.Print("\x1b_Ga=d\x1b\\")
+	}
+	fmt.Print("\x1b[H\x1b[2J")
+}
+
+// isKittyTerminal reports whether the environment indicates a terminal that
+// speaks the kitty graphics protocol.
+func isKittyTerminal() bool {
+	return os.Getenv("KITTY_WINDOW_ID") != "" ||
+		strings.Contains(strings.ToLower(os.Getenv("TERM")), "kitty")
+}
+
+// displayITerm displays the image using the iTerm2 inline image protocol.
+// When the user has specified a scale factor, or with cfg.HiDPI, the terminal
+// is asked to draw the image at displaySize, so that a HiDPI image is shown
+// at the size of a plain one with its extra pixels used for sharpness.
+func displayITerm(filename string, img image.Image, cfg Config) error {
+	data, err := pngData(filename, img)
+	if err != nil {
+		return err
+	}
+
+	size := ""
+	if cfg.Scale != 1.0 || cfg.HiDPI {
+		width, height := displaySize(cfg)
+		size = fmt.Sprintf(";width=%dpx;height=%dpx", width, height)
+	}
+	return writeITerm(os.Stdout, data, size)
+}
+
+// writeITerm writes image data to w as an iTerm2 OSC 1337 inline file. The
+// size argument holds extra ";key=value" arguments such as the display width.
+func writeITerm(w io.Writer, data []byte, size string) error {
+	_, err := fmt.Fprintf(w, "\x1b]1337;File=inline=1;size=%d%s;preserveAspectRatio=1:%s\a\n",
+		len(data), size, base64.StdEncoding.EncodeToString(data))
+	return err
+}
+
+// isITermTerminal reports whether the program runs inside iTerm2.
+func isITermTerminal() bool {
+	return os.Getenv("TERM_PROGRAM") == "iTerm.app"
+}
+
+// scaledPNG returns img as PNG data. When the user has specified a scale
+// factor, the image is resized to sentSize, matching the size used for SIXEL
+// output; otherwise the saved PNG filename, if any, is reused as it is.
+func scaledPNG(filename string, img image.Image, cfg Config) ([]byte, error) {
+	if cfg.Scale == 1.0 {
+		return pngData(filename, img)
+	}
+	width, height := sentSize(cfg)
+	return encodePNG(scaleImage(img, width, height))
+}
+
+// displaySize returns the size in pixels at which the plot should appear in
+// the terminal: cfg.Width by cfg.Height times cfg.Scale when a scale factor is
+// given, else the size rendered at cfg.DPI. It does not depend on cfg.HiDPI.
+func displaySize(cfg Config) (width, height int) {
+	if cfg.Scale != 1.0 {
+		return int(float64(cfg.Width) * cfg.Scale), int(float64(cfg.Height) * cfg.Scale)
+	}
+	return cfg.Width * cfg.DPI / 72, cfg.Height * cfg.DPI / 72
+}
+
+// sentSize returns the size in pixels of the image sent to the terminal for
+// display at displaySize: hidpiFactor times larger with cfg.HiDPI, for the
+// terminal to scale down. Without a scale factor it is the rendered size.
+func sentSize(cfg Config) (width, height int) {
+	width, height = displaySize(cfg)
+	if cfg.HiDPI {
+		width, height = width*hidpiFactor, height*hidpiFactor
+	}
+	return width, height
+}
+
+// pngData returns the contents of the PNG file filename, or img encoded as
+// PNG when filename is empty.
+func pngData(filename string, img image.Image) ([]byte, error) {
+	if filename == "" {
+		return encodePNG(img)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("read image file: %w", err)
+	}
+	return data, nil
+}
+
+// encodePNG returns img encoded as PNG.
+func encodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("encode PNG: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// flattenImage composites img onto an opaque background of color bg.
+func flattenImage(img image.Image, bg color.Color) image.Image {
+	dst := image.NewRGBA(img.Bounds())
+	xdraw.Draw(dst, dst.Bounds(), image.NewUniform(bg), image.Point{}, xdraw.Src)
+	xdraw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, xdraw.Over)
+	return dst
+}
+
+// scaleImage resizes img to width x height pixels.
+func scaleImage(img image.Image, width, height int) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	xdraw.ApproxBiLinear.Scale(dst, dst.Bounds(), img, img.Bounds(), xdraw.Src, nil)
+	return dst
+}
+
+// -----------------------------------------------------------------------------
+// Text Fallback
+// -----------------------------------------------------------------------------
+
+// brailleBase is the code point of the empty braille pattern. Each braille
+// character holds a 2x4 grid of dots, one bit per dot.
+const brailleBase = 0x2800
+
+// brailleDots maps the (column, row) position within a braille cell to its
+// bit in the pattern.
+var brailleDots = [2][4]rune{
+	{0x01, 0x02, 0x04, 0x40},
+	{0x08, 0x10, 0x20, 0x80},
+}
+
+// terminalSize returns the size of the terminal on stdout in characters,
+// defaulting to 80x24 when it cannot be determined.
+func terminalSize() (cols, rows int) {
+	cols, rows, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || cols <= 0 || rows <= 0 {
+		return 80, 24
+	}
+	return cols, rows
+}
+
+// renderBrailleSeries renders every series onto a shared grid of cols x rows
+// braille characters, followed by a line giving the plotted X and Y ranges.
+// Fixed axis bounds in cfg override the data range.
+func renderBrailleSeries(series []Series, cols, rows int, cfg Config) string {
+	cols, rows = max(cols, 2), max(rows, 2)
+	width, height := cols*2, rows*4
+
+	// Determine the plotted range
+	xmin, xmax := math.Inf(1), math.Inf(-1)
+	ymin, ymax := math.Inf(1), math.Inf(-1)
+	for _, s := range series {
+		for _, pt := range finitePoints(s.Points) {
+			xmin, xmax = math.Min(xmin, pt.X), math.Max(xmax, pt.X)
+			ymin, ymax = math.Min(ymin, pt.Y), math.Max(ymax, pt.Y)
+		}
+	}
+	for _, b := range []struct {
+		dst *float64
+		v   float64
+	}{{&xmin, cfg.XMin}, {&xmax, cfg.XMax}, {&ymin, cfg.YMin}, {&ymax, cfg.YMax}} {
+		if !math.IsNaN(b.v) {
+			*b.dst = b.v
+		}
+	}
+
+	// Map data coordinates to dot positions; a degenerate range is centered
+	toDot := func(v, lo, hi float64, n int, flip bool) int {
+		if hi <= lo {
+			return n / 2
+		}
+		f := (v - lo) / (hi - lo)
+		if flip {
+			f = 1 - f
+		}
+		return int(math.Round(f * float64(n-1)))
+	}
+
+	grid := make([][]rune, rows)
+	for i := range grid {
+		grid[i] = make([]rune, cols)
+	}
+	set := func(x, y int) {
+		if x >= 0 && x < width && y >= 0 && y < height {
+			grid[y/4][x/2] |= brailleDots[x%2][y%4]
+		}
+	}
+
+	for _, s := range series {
+		prevX, prevY, havePrev := 0, 0, false
+		for _, pt := range s.Points {
+			if !isFinite(pt.X) || !isFinite(pt.Y) {
+				havePrev = false // Leave a gap
+				continue
+			}
+			x := toDot(pt.X, xmin, xmax, width, false)
+			y := toDot(pt.Y, ymin, ymax, height, true)
+			if havePrev {
+				drawDotLine(prevX, prevY, x, y, set)
+			} else {
+				set(x, y)
+			}
+			prevX, prevY, havePrev = x, y, true
+		}
+	}
+
+	var b strings.Builder
+	for _, row := range grid {
+		for _, dots := range row {
+			b.WriteRune(brailleBase + dots)
+		}
+		b.WriteByte('\n')
+	}
+	fmt.Fprintf(&b, "x: [%.4g, %.4g]  y: [%.4g, %.4g]\n", xmin, xmax, ymin, ymax)
+	return b.String()
+}
+
+// drawDotLine calls set for each dot on the line from (x0, y0) to (x1, y1)
+// using Bresenham's algorithm.
+func drawDotLine(x0, y0, x1, y1 int, set func(x, y int)) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	for e := dx + dy; ; {
+		set(x0, y0)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		if e2 := 2 * e; e2 >= dy {
+			e += dy
+			x0 += sx
+		} else if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+// abs returns the absolute value of n.
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
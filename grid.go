@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// -----------------------------------------------------------------------------
+// Grid Layout
+// -----------------------------------------------------------------------------
+
+// gridPad is the space between the panels of a grid and around its edge.
+const gridPad = 8
+
+// parseGrid parses a -grid value of the form "ROWSxCOLS", e.g. "2x3".
+func parseGrid(s string) (rows, cols int, err error) {
+	r, c, ok := strings.Cut(strings.ToLower(s), "x")
+	if !ok {
+		return 0, 0, fmt.Errorf("expected ROWSxCOLS, got %q", s)
+	}
+	if rows, err = strconv.Atoi(strings.TrimSpace(r)); err != nil || rows < 1 {
+		return 0, 0, fmt.Errorf("invalid row count %q", r)
+	}
+	if cols, err = strconv.Atoi(strings.TrimSpace(c)); err != nil || cols < 1 {
+		return 0, 0, fmt.Errorf("invalid column count %q", c)
+	}
+	return rows, cols, nil
+}
+
+// createGrid plots each dataset, read from the matching input in cfg.Inputs,
+// in its own panel of a cfg.GridRows by cfg.GridCols grid, filled row by row,
+// and saves the figure to each of outFiles, returning the rendered image like
+// createPlot. With cfg.ShareAxes all panels use the same axis ranges. A
+// cfg.Title names the whole figure, above the panels.
+func createGrid(sets []Dataset, outFiles []string, cfg Config) (image.Image, error) {
+	plots := make([][]*plot.Plot, cfg.GridRows)
+	for i := range plots {
+		plots[i] = make([]*plot.Plot, cfg.GridCols)
+	}
+
+	var panels []*plot.Plot
+	for i, data := range sets {
+		// Each panel is titled after its input instead of the figure
+		panelCfg := cfg
+		panelCfg.Title = inputBase(cfg.Inputs[i])
+		panelCfg.Width = cfg.Width / cfg.GridCols
+		panelCfg.Height = cfg.Height / cfg.GridRows
+
+		start := time.Now()
+		p, err := newPlot(data, panelCfg)
+		if err != nil {
+			return nil, fmt.Errorf("panel for %s: %w", panelCfg.Title, err)
+		}
+		cfg.Timings.since("build", start)
+		plots[i/cfg.GridCols][i%cfg.GridCols] = p
+		panels = append(panels, p)
+	}
+	if cfg.ShareAxes {
+		shareAxes(panels)
+	}
+
+	tiles := draw.Tiles{
+		Rows: cfg.GridRows, Cols: cfg.GridCols,
+		PadX: vg.Points(gridPad), PadY: vg.Points(gridPad),
+		PadTop: vg.Points(gridPad), PadBottom: vg.Points(gridPad),
+		PadLeft: vg.Points(gridPad), PadRight: vg.Points(gridPad),
+	}
+	return saveFigures(outFiles, cfg, func(dc draw.Canvas) {
+		dc.SetColor(figureBackground(cfg))
+		dc.Fill(dc.Rectangle.Path())
+		if cfg.Title != "" {
+			// Styled like the panel titles, which it pushes down
+			style := panels[0].Title.TextStyle
+			dc.FillText(style, vg.Point{X: dc.Center().X, Y: dc.Max.Y - vg.Points(gridPad)}, cfg.Title)
+			dc.Max.Y -= style.Height(cfg.Title) + vg.Points(gridPad)
+		}
+
+		canvases := plot.Align(plots, tiles, dc)
+		for i, row := range plots {
+			for j, p := range row {
+				if p != nil {
+					p.Draw(canvases[i][j])
+				}
+			}
+		}
+	})
+}
+
+// shareAxes widens the axes of every plot to the union of their ranges.
+func shareAxes(plots []*plot.Plot) {
+	xmin, xmax := math.Inf(1), math.Inf(-1)
+	ymin, ymax := math.Inf(1), math.Inf(-1)
+	for _, p := range plots {
+		xmin, xmax = math.Min(xmin, p.X.Min), math.Max(xmax, p.X.Max)
+		ymin, ymax = math.Min(ymin, p.Y.Min), math.Max(ymax, p.Y.Max)
+	}
+	for _, p := range plots {
+		p.X.Min, p.X.Max = xmin, xmax
+		p.Y.Min, p.Y.Max = ymin, ymax
+	}
+}
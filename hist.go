@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+)
+
+// -----------------------------------------------------------------------------
+// Histograms
+// -----------------------------------------------------------------------------
+
+// addHist adds a frequency histogram of the Y values of s to p, using
+// cfg.Bins bins or, when that is 0, a count chosen by Sturges' rule. With
+// legend set, the histogram is also listed in the plot legend.
+func addHist(p *plot.Plot, s Series, c color.Color, legend bool, cfg Config) error {
+	var values plotter.Values
+	for _, pt := range s.Points {
+		if isFinite(pt.Y) {
+			values = append(values, pt.Y)
+		}
+	}
+	if len(values) == 0 {
+		return fmt.Errorf("no finite values")
+	}
+
+	bins := cfg.Bins
+	if bins == 0 {
+		bins = sturgesBins(len(values))
+	}
+
+	hist, err := plotter.NewHist(values, bins)
+	if err != nil {
+		return fmt.Errorf("create histogram: %w", err)
+	}
+	hist.LineStyle.Color = c
+	hist.LineStyle.Width = vg.Points(cfg.LineWidth)
+	if legend {
+		// Translucent bars keep overlapping histograms readable
+		hist.FillColor = fade(c, 0x60)
+	} else {
+		hist.FillColor = fade(c, 0x30)
+	}
+
+	p.Add(hist)
+	if legend {
+		p.Legend.Add(s.Label, hist)
+	}
+	return nil
+}
+
+// sturgesBins returns the number of histogram bins for n values by Sturges'
+// rule, ceil(log2 n) + 1.
+func sturgesBins(n int) int {
+	return int(math.Ceil(math.Log2(float64(n)))) + 1
+}
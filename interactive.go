@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// -----------------------------------------------------------------------------
+// Interactive Mode
+// -----------------------------------------------------------------------------
+
+const (
+	panStep  = 0.1  // Fraction of the visible range an arrow key pans by
+	zoomStep = 1.25 // Factor the visible range shrinks or grows by per key
+)
+
+// view is the visible part of the data in interactive mode: the bounds of the
+// plotted X and Y axes.
+type view struct {
+	xmin, xmax, ymin, ymax float64
+}
+
+// interactive plots the inputs in the terminal and lets the user explore them
+// from the keyboard: the arrow keys (or h, j, k, l) pan, + and - zoom about
+// the center, r restores the initial view, and q or Ctrl-C quits. Every change
+// of view re-renders the plot with the new fixed axis bounds. Nothing is
+// saved to a file.
+func interactive(cfg Config) error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return fmt.Errorf("-interactive needs a terminal on standard input and output")
+	}
+
+	sets, err := readInputs(cfg)
+	if err != nil {
+		return err
+	}
+	data := combineDatasets(sets, cfg)
+
+	// Detect the protocol before raw mode, since a SIXEL query reads the
+	// terminal's reply from the keyboard input
+	cfg = resolveProtocol(cfg)
+	if cfg.Protocol == "none" {
+		return fmt.Errorf("-interactive needs a terminal that can show graphics or text, but -protocol resolved to none")
+	}
+
+	// The automatic axis ranges of a plot with the given bounds are the
+	// initial view
+	p, err := newPlot(data, cfg)
+	if err != nil {
+		return err
+	}
+	home := view{p.X.Min, p.X.Max, p.Y.Min, p.Y.Max}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("enter raw terminal mode: %w", err)
+	}
+	defer term.Restore(fd, state)
+
+	v := home
+	for {
+		if err := drawView(data, v, cfg); err != nil {
+			return err
+		}
+		key, err := readKey(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("read key: %w", err)
+		}
+
+		// The inverted scale of a reversed axis flips the direction in which
+		// its values increase on screen
+		dx, dy := panStep, panStep
+		if cfg.XReverse {
+			dx = -dx
+		}
+		if cfg.YReverse {
+			dy = -dy
+		}
+		switch key {
+		case "q", "Q", "\x03", "\x1b":
+			fmt.Print("\r\n")
+			return nil
+		case "left", "h":
+			v.xmin, v.xmax = panRange(v.xmin, v.xmax, -dx, cfg.LogX)
+		case "right", "l":
+			v.xmin, v.xmax = panRange(v.xmin, v.xmax, dx, cfg.LogX)
+		case "up", "k":
+			v.ymin, v.ymax = panRange(v.ymin, v.ymax, dy, cfg.LogY)
+		case "down", "j":
+			v.ymin, v.ymax = panRange(v.ymin, v.ymax, -dy, cfg.LogY)
+		case "+", "=":
+			v.xmin, v.xmax = zoomRange(v.xmin, v.xmax, 1/zoomStep, cfg.LogX)
+			v.ymin, v.ymax = zoomRange(v.ymin, v.ymax, 1/zoomStep, cfg.LogY)
+		case "-", "_":
+			v.xmin, v.xmax = zoomRange(v.xmin, v.xmax, zoomStep, cfg.LogX)
+			v.ymin, v.ymax = zoomRange(v.ymin, v.ymax, zoomStep, cfg.LogY)
+		case "r", "R":
+			v = home
+		}
+	}
+}
+
+// drawView clears the terminal and shows data with the axes fixed to v,
+// followed by a status line with the visible ranges and the keys. The
+// terminal is in raw mode, so line breaks need an explicit carriage return.
+func drawView(data Dataset, v view, cfg Config) error {
+	cfg.XMin, cfg.XMax, cfg.YMin, cfg.YMax = v.xmin, v.xmax, v.ymin, v.ymax
+
+	clearScreen()
+	if cfg.Protocol == "text" {
+		cols, rows := terminalSize()
+		text := renderBrailleSeries(data.Series, cols, rows-brailleReservedRows-1, cfg)
+		fmt.Print(strings.ReplaceAll(text, "\n", "\r\n"))
+	} else {
+		p, err := newPlot(data, cfg)
+		if err != nil {
+			return err
+		}
+		img := rasterFigure(cfg, p.Draw).Image()
+		if err := displayImage("", img, data, cfg); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("\r\nx: [%.4g, %.4g]  y: [%.4g, %.4g]  arrows pan, +/- zoom, r reset, q quit", v.xmin, v.xmax, v.ymin, v.ymax)
+	return nil
+}
+
+// readKey waits for a key press on r, which must be a terminal in raw mode,
+// and returns the arrow keys as "up", "down", "left", or "right" and anything
+// else as the bytes read.
+func readKey(r io.Reader) (string, error) {
+	buf := make([]byte, 16)
+	n, err := r.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	key := string(buf[:n])
+	switch key {
+	case "\x1b[A", "\x1bOA":
+		return "up", nil
+	case "\x1b[B", "\x1bOB":
+		return "down", nil
+	case "\x1b[C", "\x1bOC":
+		return "right", nil
+	case "\x1b[D", "\x1bOD":
+		return "left", nil
+	}
+	return key, nil
+}
+
+// panRange shifts the range [lo, hi] by frac of its width, measured in
+// decades on a log axis so that panning keeps the bounds positive.
+func panRange(lo, hi, frac float64, logScale bool) (float64, float64) {
+	if logScale {
+		llo, lhi := math.Log10(lo), math.Log10(hi)
+		d := frac * (lhi - llo)
+		return math.Pow(10, llo+d), math.Pow(10, lhi+d)
+	}
+	d := frac * (hi - lo)
+	return lo + d, hi + d
+}
+
+// zoomRange scales the width of the range [lo, hi] by factor about its center,
+// measured in decades on a log axis. A factor below 1 zooms in.
+func zoomRange(lo, hi, factor float64, logScale bool) (float64, float64) {
+	if logScale {
+		llo, lhi := math.Log10(lo), math.Log10(hi)
+		mid, half := (llo+lhi)/2, factor*(lhi-llo)/2
+		return math.Pow(10, mid-half), math.Pow(10, mid+half)
+	}
+	mid, half := (lo+hi)/2, factor*(hi-lo)/2
+	return mid - half, mid + half
+}
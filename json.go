@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// -----------------------------------------------------------------------------
+// JSON Input
+// -----------------------------------------------------------------------------
+
+// readJSON reads the named file as a JSON array of points. Elements are either
+// objects such as {"x": 1, "y": 2}, or arrays such as [1, 2] holding X and one
+// or more Y values, one series each. Without "x", or in an array of a single
+// value, X is the index of the element. Unlike line-based input, any malformed
+// element is an error, since a broken document cannot be resynchronized.
+func readJSON(filename string) (Dataset, error) {
+	file, err := openInput(filename)
+	if err != nil {
+		return Dataset{}, err
+	}
+	defer file.Close()
+
+	var elems []json.RawMessage
+	if err := json.NewDecoder(file).Decode(&elems); err != nil {
+		return Dataset{}, fmt.Errorf("decode JSON: %w", err)
+	}
+
+	var data Dataset
+	for i, elem := range elems {
+		x, ys, err := parseJSONPoint(elem, float64(i))
+		if err != nil {
+			return Dataset{}, fmt.Errorf("JSON element %d: %w", i, err)
+		}
+
+		// The first element fixes the number of series
+		if data.Series == nil {
+			data.Series = newSeries(len(ys), nil)
+			if bytes.HasPrefix(elem, []byte("{")) {
+				data.XLabel, data.Series[0].Label = "x", "y"
+			}
+		}
+		if len(ys) != len(data.Series) {
+			return Dataset{}, fmt.Errorf("JSON element %d: expected %d Y values, got %d", i, len(data.Series), len(ys))
+		}
+		for j, y := range ys {
+			data.Series[j].Points = append(data.Series[j].Points, Point{X: x, Y: y})
+		}
+	}
+	return data, nil
+}
+
+// parseJSONPoint decodes one element of a JSON input array into its X and Y
+// values, using index as X when the element has none.
+func parseJSONPoint(elem json.RawMessage, index float64) (x float64, ys []float64, err error) {
+	elem = bytes.TrimSpace(elem)
+	switch {
+	case bytes.HasPrefix(elem, []byte("{")):
+		var obj struct {
+			X *float64 `json:"x"`
+			Y *float64 `json:"y"`
+		}
+		if err := json.Unmarshal(elem, &obj); err != nil {
+			return 0, nil, err
+		}
+		if obj.Y == nil {
+			return 0, nil, fmt.Errorf(`missing "y"`)
+		}
+		x = index
+		if obj.X != nil {
+			x = *obj.X
+		}
+		return x, []float64{*obj.Y}, nil
+
+	case bytes.HasPrefix(elem, []byte("[")):
+		var values []float64
+		if err := json.Unmarshal(elem, &values); err != nil {
+			return 0, nil, err
+		}
+		switch len(values) {
+		case 0:
+			return 0, nil, fmt.Errorf("empty array")
+		case 1:
+			return index, values, nil
+		}
+		return values[0], values[1:], nil
+
+	default:
+		return 0, nil, fmt.Errorf("expected an object or an array, got %s", elem)
+	}
+}
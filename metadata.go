@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+// Metadata Directives
+// -----------------------------------------------------------------------------
+
+// Metadata holds plot settings given in a data file by directive comments of
+// the form "# key: value", such as "# title: Daily Rainfall". Flags given on
+// the command line take precedence over them.
+type Metadata struct {
+	Title  string      // "title": plot title
+	XLabel string      // "xlabel": X axis label, replacing a header's column name
+	YLabel string      // "ylabel": Y axis label, replacing a header's column name
+	Color  color.Color // "color": line color of a single series, as parsed by parseHexColor
+}
+
+// parseDirective splits the text of a comment into the key and value of a
+// metadata directive. ok is false unless the text starts with a key of
+// Metadata and a colon; keys are case-insensitive.
+func parseDirective(text string) (key, value string, ok bool) {
+	key, value, found := strings.Cut(text, ":")
+	if !found {
+		return "", "", false
+	}
+	key = strings.ToLower(strings.TrimSpace(key))
+	switch key {
+	case "title", "xlabel", "ylabel", "color":
+		return key, strings.TrimSpace(value), true
+	}
+	return "", "", false
+}
+
+// set stores the value of the directive key, as returned by parseDirective.
+// A later directive replaces an earlier one, and an invalid value leaves the
+// setting unchanged.
+func (m *Metadata) set(key, value string) error {
+	switch key {
+	case "title":
+		m.Title = value
+	case "xlabel":
+		m.XLabel = value
+	case "ylabel":
+		m.YLabel = value
+	case "color":
+		c, err := parseHexColor(value)
+		if err != nil {
+			return fmt.Errorf("invalid color %q in color directive", value)
+		}
+		m.Color = c
+	}
+	return nil
+}
+
+// commonMetadata returns the directives that all of sets agree on, so that
+// merged inputs keep the settings they share.
+func commonMetadata(sets []Dataset) Metadata {
+	m := sets[0].Meta
+	for _, data := range sets[1:] {
+		if data.Meta.Title != m.Title {
+			m.Title = ""
+		}
+		if data.Meta.XLabel != m.XLabel {
+			m.XLabel = ""
+		}
+		if data.Meta.YLabel != m.YLabel {
+			m.YLabel = ""
+		}
+		if data.Meta.Color != m.Color {
+			m.Color = nil
+		}
+	}
+	return m
+}
@@ -2,20 +2,33 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"errors"
 	"flag"
 	"fmt"
 	"image"
 	"image/color"
+	"io"
 	"log"
+	"math"
 	"os"
 	"path/filepath"
+	"runtime/debug"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/mattn/go-sixel"
+	"golang.org/x/term"
 	"gonum.org/v1/plot"
 	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
 	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+	"gonum.org/v1/plot/vg/vgimg"
 )
 
 // -----------------------------------------------------------------------------
@@ -23,12 +36,36 @@ import (
 // -----------------------------------------------------------------------------
 
 const (
-	defaultWidth     = 1200 // Default plot width in points
-	defaultHeight    = 1200 // Default plot height in points
-	defaultScale     = 1.0  // Default scale factor for SIXEL output
-	defaultLineWidth = 1.0  // Default line width in points
+	defaultWidth      = 1200 // Default plot width in points
+	defaultHeight     = 1200 // Default plot height in points
+	defaultScale      = 1.0  // Default scale factor for SIXEL output
+	defaultLineWidth  = 1.0  // Default line width in points
+	defaultMarkerSize = 2.0  // Default scatter point radius in points
+	defaultDPI        = 96   // Default raster resolution in dots per inch
+	defaultFontSize   = 12   // gonum's size of title, label, and legend text in points
+	defaultTickSize   = 10   // gonum's size of tick labels in points
+	fontScaleSize     = 600  // Plot size in points beyond which default text sizes grow with it
+	hidpiFactor       = 2    // Resolution multiplier of raster output with -hidpi
+
+	defaultStreamPoints     = 5000  // Points kept per series by -stream without -max-points
+	defaultScatterThreshold = 10000 // Series longer than this are drawn without scatter points
+	defaultFillOpacity      = 0.3   // Default opacity of the area shaded by -fill
+
+	sixelQueryTimeout  = 200 * time.Millisecond // Wait for a terminal's DA1 reply
+	followPollInterval = 200 * time.Millisecond // How often -follow checks for appended data
+	defaultInterval    = time.Second            // Default -watch/-follow redraw interval
+	defaultFrameDelay  = 200 * time.Millisecond // Default time each -animate frame is shown
+
+	defaultTitle   = "Data Plot" // Title used when none is given
+	defaultFormat  = "png"       // Default output image format
+	defaultSuffix  = "_plot"     // Default suffix of output names derived from the input
+	defaultPalette = "okabe-ito" // Default palette for multiple series
 )
 
+// version is the release version, set at build time with
+// -ldflags "-X main.version=v1.2.3".
+var version = "dev"
+
 // -----------------------------------------------------------------------------
 // Default Colors
 // -----------------------------------------------------------------------------
@@ -37,37 +74,255 @@ var (
 	defaultColors = struct {
 		line       color.Color
 		scatter    color.Color
+		fit        color.Color
 		background color.Color
+		foreground color.Color
 	}{
 		// Red line and scatter points
 		line:    color.RGBA{R: 0, G: 0, B: 0, A: 255},
 		scatter: color.RGBA{R: 0, G: 0, B: 0, A: 255},
+		// Orange fitted curve
+		fit: color.RGBA{R: 213, G: 94, B: 0, A: 255},
 		// White background
 		background: color.RGBA{R: 255, G: 255, B: 255, A: 255},
+		// Black axes and text
+		foreground: color.RGBA{R: 0, G: 0, B: 0, A: 255},
+	}
+
+	// Color presets selected with -theme; explicit color flags override them
+	themes = map[string]theme{
+		"light": {
+			line:       defaultColors.line,
+			scatter:    defaultColors.scatter,
+			background: defaultColors.background,
+			foreground: defaultColors.foreground,
+		},
+		// Light gray on charcoal
+		"dark": {
+			line:       color.RGBA{R: 224, G: 224, B: 224, A: 255},
+			scatter:    color.RGBA{R: 224, G: 224, B: 224, A: 255},
+			background: color.RGBA{R: 34, G: 34, B: 34, A: 255},
+			foreground: color.RGBA{R: 208, G: 208, B: 208, A: 255},
+		},
+	}
+
+	// Named palettes cycled through when plotting multiple series
+	palettes = map[string]palette{
+		// Okabe-Ito colorblind-friendly set
+		"okabe-ito": {
+			color.RGBA{R: 230, G: 159, B: 0, A: 255},
+			color.RGBA{R: 86, G: 180, B: 233, A: 255},
+			color.RGBA{R: 0, G: 158, B: 115, A: 255},
+			color.RGBA{R: 240, G: 228, B: 66, A: 255},
+			color.RGBA{R: 0, G: 114, B: 178, A: 255},
+			color.RGBA{R: 213, G: 94, B: 0, A: 255},
+			color.RGBA{R: 204, G: 121, B: 167, A: 255},
+			color.RGBA{R: 0, G: 0, B: 0, A: 255},
+		},
+		// Tableau 10, the matplotlib default
+		"tableau10": {
+			color.RGBA{R: 31, G: 119, B: 180, A: 255},
+			color.RGBA{R: 255, G: 127, B: 14, A: 255},
+			color.RGBA{R: 44, G: 160, B: 44, A: 255},
+			color.RGBA{R: 214, G: 39, B: 40, A: 255},
+			color.RGBA{R: 148, G: 103, B: 189, A: 255},
+			color.RGBA{R: 140, G: 86, B: 75, A: 255},
+			color.RGBA{R: 227, G: 119, B: 194, A: 255},
+			color.RGBA{R: 127, G: 127, B: 127, A: 255},
+			color.RGBA{R: 188, G: 189, B: 34, A: 255},
+			color.RGBA{R: 23, G: 190, B: 207, A: 255},
+		},
+		// Muted colors from gonum's plotutil
+		"soft": plotutil.SoftColors,
 	}
+
+	// Scatter point shapes selected with -marker
+	markers = map[string]draw.GlyphDrawer{
+		"circle":   draw.RingGlyph{},
+		"square":   draw.BoxGlyph{},
+		"triangle": draw.TriangleGlyph{},
+		"cross":    draw.CrossGlyph{},
+		"plus":     draw.PlusGlyph{},
+	}
+	// Order in which -marker cycle assigns shapes to series
+	markerCycle = []string{"circle", "square", "triangle", "cross", "plus"}
+
+	// Dash patterns selected with -line-style, alternating on and off lengths
+	lineStyles = map[string][]vg.Length{
+		"solid":   nil,
+		"dashed":  {vg.Points(6), vg.Points(4)},
+		"dotted":  {vg.Points(1), vg.Points(3)},
+		"dashdot": {vg.Points(6), vg.Points(3), vg.Points(1), vg.Points(3)},
+	}
+	// Order in which -line-style cycle assigns patterns to series
+	lineStyleCycle = []string{"solid", "dashed", "dotted", "dashdot"}
 )
 
+// theme is a set of colors for the parts of a plot.
+type theme struct {
+	line, scatter, background, foreground color.Color
+}
+
+// palette is a list of colors assigned to series in order.
+type palette []color.Color
+
+// colorForSeries returns the color of the i-th series, cycling through the
+// palette when there are more series than colors.
+func (p palette) colorForSeries(i int) color.Color {
+	return p[i%len(p)]
+}
+
 // -----------------------------------------------------------------------------
 // Config and Data Types
 // -----------------------------------------------------------------------------
 
 // Config holds all user-configurable parameters for plotting.
 type Config struct {
-	Width, Height int     // Dimensions of the plot in points
-	Scale         float64 // Scale factor for SIXEL output
-	Input         string  // Input data file
+	Width, Height int           // Dimensions of the plot in points (1/72 inch)
+	DPI           int           // Raster resolution; pixel size is Width*DPI/72 by Height*DPI/72
+	HiDPI         bool          // Render raster output at hidpiFactor times DPI for HiDPI screens
+	Scale         float64       // Scale factor for SIXEL output
+	Sixel         string        // SIXEL display: "auto" (detect), "always", or "never"
+	Protocol      string        // Terminal graphics protocol: "auto", "sixel", "kitty", "iterm", "text", or "none"
+	Inputs        []string      // Input data files, or "-" for stdin
+	Watch         bool          // Re-render whenever an input file changes
+	Follow        bool          // Keep reading lines appended to the inputs, like tail -f
+	Interactive   bool          // Explore the plot in the terminal with keys to pan and zoom
+	Interval      time.Duration // Polling and redraw interval for Watch and Follow
+	Animate       string        // GIF file receiving each input as a frame of an animation; empty disables
+	FrameDelay    time.Duration // Time each Animate frame is shown
+	DryRun        bool          // Only read and check the inputs, without plotting
+	Quiet         bool          // Suppress informational log messages
+	Verbose       bool          // Log timing and point-count diagnostics
+	Profile       bool          // Log how long each stage of the run took
+	CPUProfile    string        // File receiving a pprof CPU profile; empty disables
+	Timings       *stageTimes   // Stage timings collected for Profile; nil when disabled
+	Output        string        // Output image file; derived from the first input when empty
+	NoFile        bool          // Display the plot without writing an image file
+	OutDir        string        // Directory for output files; beside the input when empty
+	MirrorDirs    bool          // Keep the input's relative directory below OutDir
+	Suffix        string        // Added to the input's name to derive the output name
+	NameTemplate  string        // Derived output name with {name}, {ext}, {date}, and {time}; Suffix is used when empty
+	Format        string        // Comma-separated output formats: png, jpeg, svg, pdf, ...; ignored when Output is set
+	InFormat      string        // Input format: "auto" (by extension), "whitespace", "csv", "tsv", or "json"
+	Delimiter     string        // Field delimiter: "auto", "whitespace", "tab", or a single character
+	Decimal       string        // Decimal separator of input numbers: "." or ","
+	Columns       []int         // Field indices plotted as Y series against field 0; all when empty
+	UseCols       []int         // Fields kept from each line, the first as X; all when empty
+	XName         string        // Header name of the X column; field 0 when empty
+	XCol          string        // "first": field 0 is X on lines of two or more fields; "none": every field is Y against the line index
+	YNames        []string      // Header names of the Y columns; all but X when empty
+	NaN           string        // Handling of NaN/Inf values: "skip", "gap", or "error"
+	Comment       string        // Comment prefixes: single characters, or a comma-separated list
+	Skip          int           // Number of leading non-comment lines to discard
+	SkipCols      int           // Number of leading fields dropped from each line
+	XTime         string        // Layout of timestamps in the X field; empty for numbers
+	Strict        bool          // Fail on the first malformed line instead of skipping it
+	Stream        bool          // Downsample while reading so memory stays bounded
+	Header        string        // Header row of column names: "auto" (detect), "always", or "never"
+	Title         string        // Plot title; defaultTitle when empty
+	Font          string        // TrueType or OpenType font file used for all text; gonum's default when empty
+	FontSize      float64       // Size of axis label, legend, and annotation text in points; 0 scales with the plot
+	TitleSize     float64       // Size of the title in points; 0 scales with the plot
+	TickSize      float64       // Size of tick labels in points; 0 scales with the plot
+	XLabel        string        // X axis label; taken from the file header or "X" when empty
+	YLabel        string        // Y axis label; taken from the file header or "Y" when empty
+	Labels        []string      // Legend labels overriding the series names, in order
+	LegendPos     string        // Legend placement: a corner such as "top-right", "top", or "none"
+	Palette       string        // Name of the palette used for multiple series
+	LogX, LogY    bool          // Use logarithmic scaling on the X or Y axis
+	Transpose     bool          // Plot the data's X values up the Y axis and its Y values along X
+	XReverse      bool          // Draw the X axis from right to left
+	YReverse      bool          // Draw the Y axis from top to bottom
+	MinorTicks    bool          // Draw unlabeled minor ticks between the labeled ones
+	TickFormat    string        // Numeric tick label format: "plain", "sci", "eng", or "comma"; gonum's default when empty
+	Equal         bool          // Scale both axes alike, so that shapes are not distorted
+	Margin        float64       // Padding in points between each axis and the data; NaN keeps gonum's default
+
+	XMin, XMax, YMin, YMax float64 // Fixed axis bounds; NaN leaves a bound auto-scaled
+	Clip                   bool    // Leave out points beyond the fixed axis bounds
+	ClipOutliers           bool    // Limit the Y range to the values within Tukey's fences
+
+	GridRows, GridCols int  // Panels of a grid with one input each; 0 overlays the inputs
+	ShareAxes          bool // Give every grid panel the same axis ranges
+
+	Y2Series []int  // Numbers (from 1) of the series drawn against a right-hand Y axis
+	Y2Label  string // Right-hand Y axis label; the series label when only one uses it
+	Band     []int  // Columns of the lower and upper bounds of a shaded band
 
-	LineWidth float64 // Width of the plot line in points
+	HLines, VLines []float64    // Y values of horizontal and X values of vertical reference lines
+	Annotations    []Annotation // Text labels placed at data coordinates
+
+	LineWidth    float64       // Width of the plot line in points
+	NoPoints     bool          // Draw only the line, without scatter points
+	ScatterOnly  bool          // Draw only the scatter points, without the line
+	LineStyle    string        // Line dash pattern, a key of lineStyles or "cycle"
+	Marker       string        // Scatter point shape, a key of markers or "cycle"
+	MarkerSize   float64       // Scatter point radius in points
+	Smooth       int           // Moving-average window for the line (odd, >= 3); 0 disables
+	SmoothMedian int           // Moving-median window for the line (>= 2); 0 disables
+	Step         string        // Staircase line: "pre", "post", or "none"
+	GapThreshold float64       // Break lines where neighbouring X values are further apart; 0 never does
+	MaxPoints    int           // Downsample series longer than this for drawing; 0 disables
+	ScatterLimit int           // Draw series longer than this without scatter points; 0 disables
+	Fill         bool          // Shade the area between each line and Y = 0
+	FillOpacity  float64       // Opacity of the shaded area, from 0 to 1
+	Fit          string        // Curve fitted to each series and overlaid: "linear"; empty disables
+	Stats        string        // Summary statistics drawn for each series: "mean", "minmax", or "stddev"; empty disables
+	PrintStats   bool          // Print a table of each series' summary statistics to stdout
+	StatsFormat  string        // Format of PrintStats: "table" or "json"
+	ASCII        bool          // Print the plotted points as an aligned text table to stdout
+	Transform    string        // Transformation of the Y values before plotting, see applyTransform; empty disables
+	Sort         bool          // Connect the points in order of X rather than input order
+	ErrorBars    bool          // Read Y errors from the columns after Y and draw them as error bars
+	SeriesStyles []SeriesStyle // Per-column overrides of colors, line width, and style
+	Colormap     string        // Color map coloring scatter points by the column after Y, a key of colormaps
+	Hist         bool          // Plot a frequency histogram of the Y values instead of lines
+	Bar          bool          // Plot a bar chart of categories named by the first field
+	Box          bool          // Plot a box-and-whisker plot of each series' values side by side
+	Bins         int           // Number of histogram bins; 0 picks one from the data
 
 	// Colors for different plot elements
 	Colors struct {
-		Line, Scatter, Background color.Color
+		Line, Scatter, Background, Foreground color.Color
+		RefLine                               color.Color // Reference lines; a faded foreground when nil
+		Fill                                  color.Color // Area under a single series; its line color when nil
 	}
+	Theme       string // Color preset: "light" or "dark"
+	Transparent bool   // Leave the figure background transparent; Colors.Background still backs the SIXEL preview
+	CMYK        bool   // Write the colors of PDF output in the DeviceCMYK color space, for print
+
+	Explicit map[string]bool // Names of the flags given explicitly, which data file directives do not override
 }
 
 // Point represents a single (X, Y) coordinate.
 type Point struct {
 	X, Y float64
+
+	ErrLow, ErrHigh float64 // Y error below and above the point; zero without -errorbars
+	Z               float64 // Value shown as the point's color with -colormap
+}
+
+// Series is a labeled sequence of points drawn as one line.
+type Series struct {
+	Label  string
+	Column int // Field index the Y values were read from
+	Points []Point
+}
+
+// Dataset is the parsed content of one input file.
+type Dataset struct {
+	XLabel, YLabel string   // Axis labels found in a header comment, if any
+	Meta           Metadata // Settings from directive comments such as "# title: ..."
+	Series         []Series
+	Categories     []string // Names of the bars at X = 0, 1, ... with -bar
+
+	Skipped      int    // Number of malformed lines that were skipped
+	SkippedLines []int  // Line numbers of the first few skipped lines
+	SkipReason   string // Why the first skipped line was rejected
+
+	NonFinite      int   // Number of lines whose NaN/Inf values were dropped with -nan skip
+	NonFiniteLines []int // Line numbers of the first few of those lines
 }
 
 // -----------------------------------------------------------------------------
@@ -85,225 +340,3042 @@ func main() {
 // assigning their values into a Config struct.
 func parseFlags() Config {
 	var cfg Config
+	defineFlags(flag.CommandLine, &cfg)
 
-	// Define CLI flags with usage text
-	flag.IntVar(&cfg.Width, "w", defaultWidth, "plot width in points")
-	flag.IntVar(&cfg.Height, "h", defaultHeight, "plot height in points")
-	flag.Float64Var(&cfg.Scale, "s", defaultScale, "SIXEL scale factor")
-	flag.Float64Var(&cfg.LineWidth, "line-width", defaultLineWidth, "line width in points")
+	showVersion := flag.Bool("version", false, "print version information and exit")
+	flag.String("config", "", "read default settings from this file (default ~/"+defaultConfigFile+")")
+
+	// Settings from the config file and environment act as defaults for
+	// the command line
+	if err := applyConfigFile(flag.CommandLine, os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+	if err := applyEnv(flag.CommandLine); err != nil {
+		log.Fatal(err)
+	}
+	flag.Usage = usage
 
 	flag.Parse()
 
-	// Expect exactly one input filename
-	if flag.NArg() != 1 {
-		log.Fatal("Usage: plotter [options] data_file")
+	if *showVersion {
+		fmt.Println(versionString())
+		os.Exit(0)
+	}
+
+	// Expect at least one input filename
+	if flag.NArg() < 1 {
+		log.Fatal("Usage: plotter [options] data_file...  (use - to read from stdin)")
 	}
 
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	applyThemeColors(&cfg, set)
+
 	// Set Config fields
-	cfg.Input = flag.Arg(0)
+	cfg.Inputs = flag.Args()
+	cfg.Explicit = set
+
+	return cfg
+}
+
+// DefaultConfig returns the settings used when no command-line flags are
+// given, as a starting point for RenderPNG and RenderSixel.
+func DefaultConfig() Config {
+	var cfg Config
+	defineFlags(flag.NewFlagSet("plotview", flag.ContinueOnError), &cfg)
+	applyThemeColors(&cfg, nil)
+	return cfg
+}
+
+// defineFlags defines the plotting flags in fs, each storing its value in
+// cfg, which receives the flag defaults right away.
+func defineFlags(fs *flag.FlagSet, cfg *Config) {
 	cfg.Colors.Line = defaultColors.line
 	cfg.Colors.Scatter = defaultColors.scatter
 	cfg.Colors.Background = defaultColors.background
 
-	return cfg
+	// Define CLI flags with usage text
+	fs.IntVar(&cfg.Width, "w", defaultWidth, "plot width in points")
+	fs.IntVar(&cfg.Height, "h", defaultHeight, "plot height in points")
+	fs.IntVar(&cfg.DPI, "dpi", defaultDPI, "raster output resolution; -w and -h keep the physical size, so pixels = points*dpi/72")
+	fs.Float64Var(&cfg.Scale, "s", defaultScale, "SIXEL scale factor")
+	fs.BoolVar(&cfg.HiDPI, "hidpi", false, "render raster output at twice -dpi and send the terminal twice the pixels of the display size (set by -s), for crisp plots on HiDPI screens")
+	fs.StringVar(&cfg.Protocol, "protocol", "auto", `terminal graphics protocol: "auto", "sixel", "kitty", "iterm", "text" (Unicode braille), or "none"`)
+	fs.StringVar(&cfg.Sixel, "sixel", "auto", `SIXEL display: "auto" detects terminal support, "always", or "never"`)
+	fs.Float64Var(&cfg.LineWidth, "line-width", defaultLineWidth, "line width in points")
+	fs.BoolVar(&cfg.NoPoints, "no-points", false, "draw the line only, without scatter points")
+	fs.BoolVar(&cfg.ScatterOnly, "scatter-only", false, "draw scatter points only, without the line")
+	fs.StringVar(&cfg.LineStyle, "line-style", "solid", `line dash pattern: "solid", "dashed", "dotted", "dashdot", or "cycle" to vary it by series`)
+	fs.StringVar(&cfg.Marker, "marker", "circle", `scatter point shape: "circle", "square", "triangle", "cross", "plus", or "cycle" to vary it by series`)
+	fs.Float64Var(&cfg.MarkerSize, "marker-size", defaultMarkerSize, "scatter point radius in points")
+	fs.StringVar(&cfg.Theme, "theme", "light", `color preset: "light" or "dark"`)
+	fs.Func("series", `style the series of one column, as "col=N" plus any of color=#RRGGBB, width=W, style=S, and marker=M (repeatable)`, func(s string) error {
+		st, err := parseSeriesStyle(s)
+		if err != nil {
+			return err
+		}
+		cfg.SeriesStyles = append(cfg.SeriesStyles, st)
+		return nil
+	})
+	fs.Func("line-color", "line color as #RGB, #RRGGBB, or #RRGGBBAA (default: from -theme)", colorFlag(&cfg.Colors.Line))
+	fs.Func("scatter-color", "scatter point color as #RGB, #RRGGBB, or #RRGGBBAA (default: from -theme)", colorFlag(&cfg.Colors.Scatter))
+	fs.Func("bg-color", "background color as #RGB, #RRGGBB, or #RRGGBBAA (default: from -theme)", colorFlag(&cfg.Colors.Background))
+	fs.BoolVar(&cfg.Transparent, "transparent", false, "leave the background of PNG, TIFF, and vector output transparent; the SIXEL preview is shown on -bg-color")
+	fs.BoolVar(&cfg.CMYK, "cmyk", false, "write PDF colors as CMYK for print; colors given as cmyk:C/M/Y/K percentages (e.g. -line-color cmyk:0/60/100/0) keep their exact inks, others are converted without a color profile")
+	fs.Func("fg-color", "color of the title, axes, ticks, and labels as #RGB, #RRGGBB, or #RRGGBBAA (default: contrasts with the background)", colorFlag(&cfg.Colors.Foreground))
+	fs.IntVar(&cfg.Smooth, "smooth", 0, "draw an N-point centered moving average over the raw points (N odd, >= 3)")
+	fs.IntVar(&cfg.SmoothMedian, "smooth-median", 0, "draw an N-point moving median over the raw points, ignoring spikes (N >= 2)")
+	fs.BoolVar(&cfg.ErrorBars, "errorbars", false, "read \"x y yerr\" or \"x y ylow yhigh\" columns and draw Y error bars")
+	fs.StringVar(&cfg.Colormap, "colormap", "", `read "x y z" columns and color each point by z: "viridis", "plasma", "kindlmann", "blackbody", or "bluered"`)
+	fs.BoolVar(&cfg.Hist, "hist", false, "plot a frequency histogram of the Y values instead of lines and points")
+	fs.BoolVar(&cfg.Bar, "bar", false, "plot a bar chart; the first field of each line names its category")
+	fs.BoolVar(&cfg.Box, "box", false, "compare distributions in box plots, one per input file or, within a file, per column; every field is a sample value")
+	fs.IntVar(&cfg.Bins, "bins", 0, "number of -hist bins (default: chosen from the number of values)")
+	fs.IntVar(&cfg.MaxPoints, "max-points", 0, "downsample series with more than N points (N >= 3) to N, keeping their shape; hides scatter points")
+	fs.IntVar(&cfg.ScatterLimit, "scatter-threshold", defaultScatterThreshold, "draw series with more than N points as lines only, without scatter points; 0 always draws them")
+	fs.BoolVar(&cfg.Fill, "fill", false, "shade the area between each line and the X axis (Y = 0)")
+	fs.Func("fill-color", "color of the -fill area for a single series as #RGB, #RRGGBB, or #RRGGBBAA (default: the line color)", colorFlag(&cfg.Colors.Fill))
+	fs.Float64Var(&cfg.FillOpacity, "fill-opacity", defaultFillOpacity, "opacity of the -fill area, from 0 to 1")
+	fs.StringVar(&cfg.Step, "step", "none", `draw the line as a staircase: "pre" steps at the previous X, "post" at the next X, or "none"`)
+	fs.Func("gap-threshold", "break lines where neighbouring X values are further apart than this, as a number in X units or, for -xtime data, a duration such as 10m", func(s string) error {
+		gap, err := parseGap(s)
+		if err != nil {
+			return err
+		}
+		cfg.GapThreshold = gap
+		return nil
+	})
+	fs.BoolVar(&cfg.Sort, "sort", false, "sort each series by X before drawing its line, keeping the input order of equal X values; applied before -transform")
+	fs.StringVar(&cfg.Transform, "transform", "", `transform each series' Y values before plotting: "cumsum" (running total), "diff" (change from the previous point), "abs", or "normalize" (scale to [0, 1])`)
+	fs.BoolVar(&cfg.ASCII, "ascii", false, "print the plotted points, after -sort, -transform, and -max-points, as a text table to stdout; with -dry-run nothing is plotted")
+	fs.BoolVar(&cfg.PrintStats, "print-stats", false, "print the count, range, mean, median, and standard deviation of each series' Y values, and its X range, to stdout; with -dry-run nothing is plotted")
+	fs.StringVar(&cfg.StatsFormat, "stats-format", "table", `format of -print-stats: "table" or "json"`)
+	fs.StringVar(&cfg.Stats, "stats", "", `draw and log summary statistics of each series' Y values: "mean" line, "minmax" lines, or "stddev" band of ±1σ about the mean`)
+	fs.StringVar(&cfg.Fit, "fit", "", `overlay a least-squares fit: "linear", "poly:N" for a degree N polynomial, "exp" for a·e^(bx), or "power" for a·x^b`)
+	fs.StringVar(&cfg.Palette, "palette", defaultPalette, "colors for multiple series: okabe-ito, tableau10, or soft")
+	fs.StringVar(&cfg.Title, "title", "", `plot title, or with -grid a figure title above the panels, which are titled after their inputs (default "`+defaultTitle+`")`)
+	fs.StringVar(&cfg.Font, "font", "", "TrueType or OpenType font file to use for all text, e.g. for Greek letters or subscripts")
+	fs.Float64Var(&cfg.FontSize, "font-size", 0, "size of axis labels, legend, and annotations in points (default: 12, grown with plots over 600 points)")
+	fs.Float64Var(&cfg.TitleSize, "title-size", 0, "size of the title in points (default: 12, grown with plots over 600 points)")
+	fs.Float64Var(&cfg.TickSize, "tick-size", 0, "size of tick labels in points (default: 10, grown with plots over 600 points)")
+	fs.StringVar(&cfg.XLabel, "xlabel", "", "X axis label (default: from header comment, else \"X\")")
+	fs.StringVar(&cfg.YLabel, "ylabel", "", "Y axis label (default: from header comment, else \"Y\")")
+	fs.Func("labels", "comma-separated legend labels for the series, e.g. a,b,c", func(s string) error {
+		cfg.Labels = strings.Split(s, ",")
+		return nil
+	})
+	fs.StringVar(&cfg.LegendPos, "legend-pos", "top-right", `legend placement for several series: "top-left", "top-right", "bottom-left", "bottom-right", "top" (centered), or "none" to hide it`)
+	fs.Func("grid", "plot each input in its own panel of a ROWSxCOLS grid, e.g. 2x2", func(s string) error {
+		rows, cols, err := parseGrid(s)
+		if err != nil {
+			return err
+		}
+		cfg.GridRows, cfg.GridCols = rows, cols
+		return nil
+	})
+	fs.BoolVar(&cfg.ShareAxes, "share-axes", false, "give every -grid panel the same axis ranges")
+	fs.Func("y2-series", "comma-separated series numbers (from 1) to draw against a right-hand Y axis, e.g. 2", func(s string) error {
+		nums, err := parseIntList(s)
+		if err != nil {
+			return err
+		}
+		for _, n := range nums {
+			if n < 1 {
+				return fmt.Errorf("series number %d must be >= 1", n)
+			}
+		}
+		cfg.Y2Series = nums
+		return nil
+	})
+	fs.Func("band", `shade the band between two Y columns, given as low,high field numbers such as 1,3 for "x ylow ymid yhigh"; the bounds are not drawn as lines`, func(s string) error {
+		cols, err := parseIntList(s)
+		if err != nil {
+			return err
+		}
+		if len(cols) != 2 || cols[0] < 1 || cols[1] < 1 || cols[0] == cols[1] {
+			return fmt.Errorf("expected two different column numbers >= 1 as low,high, got %q", s)
+		}
+		cfg.Band = cols
+		return nil
+	})
+	fs.StringVar(&cfg.Y2Label, "y2label", "", "right-hand Y axis label for -y2-series (default: the series label)")
+	fs.BoolVar(&cfg.Equal, "equal", false, "use the same scale on both axes, widening one range, so shapes are not distorted")
+	fs.BoolVar(&cfg.LogX, "logx", false, "use a logarithmic X axis (all X values must be > 0)")
+	fs.BoolVar(&cfg.LogY, "logy", false, "use a logarithmic Y axis (all Y values must be > 0)")
+	fs.BoolVar(&cfg.Transpose, "transpose", false, "swap X and Y, plotting the first column up the Y axis, e.g. for depth profiles")
+	fs.BoolVar(&cfg.XReverse, "xreverse", false, "draw the X axis with values increasing to the left, e.g. for wavenumbers")
+	fs.BoolVar(&cfg.YReverse, "yreverse", false, "draw the Y axis with values increasing downward")
+	fs.StringVar(&cfg.TickFormat, "tick-format", "", `numeric tick labels: "plain" (1200000), "sci" (1.2e6), "eng" (1.2M), or "comma" (1,200,000)`)
+	fs.BoolVar(&cfg.MinorTicks, "minor-ticks", true, "draw minor ticks between labeled ones, at 2-9 within each decade on log axes")
+	fs.Float64Var(&cfg.Margin, "margin", math.NaN(), "padding in points between each axis and the data, e.g. 0 for a tight layout; NaN keeps gonum's 5")
+	fs.Float64Var(&cfg.XMin, "xmin", math.NaN(), "lower X axis bound; NaN auto-scales")
+	fs.Float64Var(&cfg.XMax, "xmax", math.NaN(), "upper X axis bound; NaN auto-scales")
+	fs.Float64Var(&cfg.YMin, "ymin", math.NaN(), "lower Y axis bound; NaN auto-scales")
+	fs.Float64Var(&cfg.YMax, "ymax", math.NaN(), "upper Y axis bound; NaN auto-scales")
+	fs.BoolVar(&cfg.Clip, "clip", false, "leave out points beyond -xmin, -xmax, -ymin, and -ymax, breaking lines there")
+	fs.BoolVar(&cfg.ClipOutliers, "clip-outliers", false, "limit the auto-scaled Y range to Q1-1.5*IQR .. Q3+1.5*IQR of the values, so that a few extreme outliers do not flatten the rest; the outliers are clipped, not removed")
+	fs.Func("hline", "draw a dashed horizontal reference line at this Y value (repeatable)", floatListFlag(&cfg.HLines))
+	fs.Func("vline", "draw a dashed vertical reference line at this X value (repeatable)", floatListFlag(&cfg.VLines))
+	fs.Func("annotate", `place a text label at a data coordinate, as "x,y,text" (repeatable)`, func(s string) error {
+		a, err := parseAnnotation(s)
+		if err != nil {
+			return err
+		}
+		cfg.Annotations = append(cfg.Annotations, a)
+		return nil
+	})
+	fs.Func("refline-color", "color of -hline and -vline as #RGB, #RRGGBB, or #RRGGBBAA (default: faded foreground)", colorFlag(&cfg.Colors.RefLine))
+	fs.BoolVar(&cfg.Watch, "watch", false, "keep running and re-render whenever an input file changes")
+	fs.BoolVar(&cfg.Quiet, "quiet", false, "suppress informational messages such as \"Plot saved to\"; warnings and errors are still shown")
+	fs.BoolVar(&cfg.Verbose, "verbose", false, "log timing and point-count diagnostics")
+	fs.BoolVar(&cfg.Profile, "profile", false, "log a breakdown of the time spent reading, parsing, building, drawing, saving, and displaying the plot")
+	fs.StringVar(&cfg.CPUProfile, "cpuprofile", "", "write a pprof CPU profile of the run to this file")
+	fs.BoolVar(&cfg.Follow, "follow", false, "keep reading lines appended to the inputs (like tail -f) and re-render")
+	fs.BoolVar(&cfg.Interactive, "interactive", false, "show the plot in the terminal and pan with the arrow keys, zoom with + and -, reset with r, and quit with q")
+	fs.StringVar(&cfg.Animate, "animate", "", "save the inputs, such as frame_001.dat frame_002.dat ..., as the frames of an animated GIF with this name, on fixed axes spanning all frames; the frames also play in the terminal")
+	fs.DurationVar(&cfg.FrameDelay, "frame-delay", defaultFrameDelay, "how long each -animate frame is shown, in steps of 10ms")
+	fs.DurationVar(&cfg.Interval, "interval", defaultInterval, "how often -watch and -follow check for changes and redraw")
+	fs.StringVar(&cfg.Output, "o", "", "output image file; its extension selects the format (default: <input>_plot.<format>)")
+	fs.StringVar(&cfg.OutDir, "outdir", "", "write output files to this directory, created if needed")
+	fs.StringVar(&cfg.Suffix, "suffix", defaultSuffix, "suffix added to the input's name to derive the output file name; may be empty")
+	fs.StringVar(&cfg.NameTemplate, "name-template", "", "derive output file names from this pattern, e.g. \"{name}_{date}.{ext}\", where {name} is the input without its extension, {ext} the format, {date} today's date, and {time} the time as HHMMSS; .{ext} is added unless the name ends with it")
+	fs.BoolVar(&cfg.MirrorDirs, "mirror-dirs", false, "with -outdir, recreate each relative input path's directories below it")
+	fs.BoolVar(&cfg.DryRun, "dry-run", false, "read and check the inputs and report their point counts without plotting; combine with -strict to fail on any malformed line")
+	fs.BoolVar(&cfg.NoFile, "no-file", false, "display the plot without writing an image file")
+	fs.StringVar(&cfg.Format, "format", defaultFormat, "output format: png, jpeg, tiff, svg, pdf, or eps, or a comma-separated list such as png,pdf to save each")
+	fs.StringVar(&cfg.InFormat, "in-format", "auto", `input format: "auto" guesses from the file extension, else "whitespace", "csv", "tsv", or "json"; useful for stdin`)
+	fs.StringVar(&cfg.Delimiter, "delimiter", "auto", `field delimiter: "auto", "whitespace", "tab", or a single character`)
+	fs.StringVar(&cfg.Decimal, "decimal", ".", `decimal separator of input numbers: "." or ","; with ",", as in European CSV files such as "1,5;2,3", fields are split on semicolons unless -delimiter says otherwise`)
+	fs.StringVar(&cfg.Comment, "comment", "#%", `characters that start a comment line, or comma-separated prefixes such as "//,;"`)
+	fs.IntVar(&cfg.Skip, "skip", 0, "discard the first N non-comment lines, e.g. an unprefixed header row")
+	fs.IntVar(&cfg.SkipCols, "skip-cols", 0, "drop the first N fields of each line, e.g. a row counter; other field indices count from the rest")
+	fs.StringVar(&cfg.XTime, "xtime", "", `parse X as timestamps in this Go time layout, e.g. "2006-01-02 15:04", or "iso", "rfc3339", "datetime", or "date"`)
+	fs.BoolVar(&cfg.Stream, "stream", false, "downsample while reading, keeping memory bounded for huge files (target: -max-points, else 5000)")
+	fs.BoolVar(&cfg.Strict, "strict", false, "fail on the first malformed line instead of skipping it")
+	fs.StringVar(&cfg.Header, "header", "auto", `first data line holds column names: "auto" if non-numeric, "always", or "never"`)
+	fs.StringVar(&cfg.NaN, "nan", "skip", `handling of NaN/Inf values: "skip" the point, leave a "gap" in the line, or "error"; missing values from empty fields are only skipped, or gaps with "gap"`)
+	fs.Func("columns", "comma-separated field indices to plot against field 0, e.g. 1,3", func(s string) error {
+		cols, err := parseIntList(s)
+		if err != nil {
+			return err
+		}
+		for _, c := range cols {
+			if c < 0 {
+				return fmt.Errorf("column index %d must be >= 0", c)
+			}
+		}
+		cfg.Columns = cols
+		return nil
+	})
+	fs.StringVar(&cfg.XName, "x", "", "header name of the column to use as X")
+	fs.StringVar(&cfg.XCol, "xcol", "first", `X column: "first", where field 0 is X whenever a line has two or more fields (a single field is Y against the line index), or "none" to plot every field, e.g. both of a 2-column file, as Y against the line index; -columns then counts Y fields from 0`)
+	fs.Func("y", `comma-separated header names of the columns to plot, e.g. "voltage,current"`, func(s string) error {
+		cfg.YNames = strings.Split(s, ",")
+		return nil
+	})
+	fs.Func("usecols", "comma-separated field indices to read, the first as X and the rest as Y, e.g. 0,2,5", func(s string) error {
+		cols, err := parseIntList(s)
+		if err != nil {
+			return err
+		}
+		for _, c := range cols {
+			if c < 0 {
+				return fmt.Errorf("field index %d must not be negative", c)
+			}
+		}
+		cfg.UseCols = cols
+		return nil
+	})
 }
 
-// run orchestrates reading the data file, creating a plot, and optionally
-// displaying the resulting image via SIXEL if the terminal supports it.
-func run(cfg Config) error {
-	points, err := readData(cfg.Input)
-	if err != nil {
-		return fmt.Errorf("reading data from %q: %w", cfg.Input, err)
+// applyThemeColors fills in the colors of cfg.Theme for the color flags not
+// in set, the names of the flags given explicitly.
+func applyThemeColors(cfg *Config, set map[string]bool) {
+	// The theme supplies the colors that were not given explicitly
+	if t, ok := themes[cfg.Theme]; ok {
+		for name, c := range map[string]struct {
+			dst *color.Color
+			src color.Color
+		}{
+			"line-color":    {&cfg.Colors.Line, t.line},
+			"scatter-color": {&cfg.Colors.Scatter, t.scatter},
+			"bg-color":      {&cfg.Colors.Background, t.background},
+			"fg-color":      {&cfg.Colors.Foreground, t.foreground},
+		} {
+			if !set[name] {
+				*c.dst = c.src
+			}
+		}
 	}
-	if len(points) == 0 {
-		return fmt.Errorf("no valid data points found in %q", cfg.Input)
+	// A custom background gets a foreground that stays readable on it
+	if set["bg-color"] && !set["fg-color"] {
+		cfg.Colors.Foreground = contrastColor(cfg.Colors.Background)
 	}
+}
 
-	// Construct output filename, e.g. "data_plot.png"
-	outFile := strings.TrimSuffix(cfg.Input, filepath.Ext(cfg.Input)) + "_plot.png"
+// usage prints the command-line help, including the environment variables
+// that supply defaults.
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintln(out, "Usage: plotter [options] data_file...  (use - to read from stdin)")
+	fmt.Fprintln(out)
+	flag.PrintDefaults()
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Defaults come from ~/"+defaultConfigFile+" (or -config), then from these")
+	fmt.Fprintln(out, "environment variables; flags on the command line override both:")
+	for _, e := range envFlags {
+		fmt.Fprintf(out, "  %-16s sets -%s\n", e.env, e.flag)
+	}
+}
 
-	if err := createPlot(points, outFile, cfg); err != nil {
-		return fmt.Errorf("creating plot: %w", err)
+// floatListFlag returns a flag.Func handler that appends a number to dst, for
+// flags that may be repeated.
+func floatListFlag(dst *[]float64) func(string) error {
+	return func(s string) error {
+		v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		*dst = append(*dst, v)
+		return nil
 	}
-	log.Printf("Plot saved to: %s", outFile)
+}
 
-	// Attempt to display the plot via SIXEL
-	if err := displaySixel(outFile, cfg); err != nil {
-		return fmt.Errorf("displaying SIXEL: %w", err)
+// colorFlag returns a flag.Func handler that parses a hex color into dst.
+func colorFlag(dst *color.Color) func(string) error {
+	return func(s string) error {
+		c, err := parseHexColor(s)
+		if err != nil {
+			return err
+		}
+		*dst = c
+		return nil
 	}
-	return nil
 }
 
-// -----------------------------------------------------------------------------
-// Reading Data
-// -----------------------------------------------------------------------------
+// versionString describes the build: the release version and, when the binary
+// carries build information, the VCS commit and Go version it was built with.
+func versionString() string {
+	v := "plotter " + version
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return v
+	}
 
-// readData opens the given file, reads it line-by-line, and converts each line
-// into either (X, Y) or (lineIndex, Y). Lines starting with '#' or '%'
-// (or blank lines) are treated as comments and skipped.
-func readData(filename string) ([]Point, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, fmt.Errorf("open file: %w", err)
+	var details []string
+	var revision string
+	var modified bool
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
 	}
-	defer file.Close()
+	if revision != "" {
+		if len(revision) > 12 {
+			revision = revision[:12]
+		}
+		if modified {
+			revision += "-dirty"
+		}
+		details = append(details, "commit "+revision)
+	} else if version == "dev" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		// Installed with go install at a module version
+		v = "plotter " + info.Main.Version
+	}
+	details = append(details, info.GoVersion)
+	return v + " (" + strings.Join(details, ", ") + ")"
+}
 
-	var (
-		points    []Point
-		scanner   = bufio.NewScanner(file)
-		lineIndex float64
-	)
+// parseHexColor parses a color written as RGB, RRGGBB, or RRGGBBAA hex digits,
+// with or without a leading '#'. The 3-digit form expands each digit, so
+// "#f80" equals "#ff8800". A color may also be given as ink percentages in
+// the form "cmyk:C/M/Y/K", which -cmyk writes to PDF output unconverted.
+func parseHexColor(s string) (color.Color, error) {
+	if inks, ok := strings.CutPrefix(strings.TrimSpace(s), "cmyk:"); ok {
+		return parseCMYK(inks)
+	}
+	hex := strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 {
+		return nil, fmt.Errorf("invalid color %q: expected #RGB, #RRGGBB, #RRGGBBAA, or cmyk:C/M/Y/K", s)
+	}
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		// Ignore empty lines or lines starting with '#' or '%'
-		if line == "" || line[0] == '#' || line[0] == '%' {
-			continue
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid color %q: bad hex digits", s)
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+// parseIntList parses a comma-separated list of integers such as "1,3,4".
+func parseIntList(s string) ([]int, error) {
+	var list []int
+	for _, f := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %q", f)
 		}
+		list = append(list, n)
+	}
+	return list, nil
+}
 
-		point, err := parseLine(line, lineIndex)
+// run validates the configuration and renders the plot once, or repeatedly
+// in watch mode. With cfg.Profile the time spent in each stage is logged
+// at the end, and with cfg.CPUProfile a CPU profile is written.
+func run(cfg Config) (err error) {
+	if err := validateConfig(cfg); err != nil {
+		return err
+	}
+	if cfg.CPUProfile != "" {
+		stop, err := startCPUProfile(cfg.CPUProfile)
 		if err != nil {
-			// Log and continue rather than abort on malformed lines
-			log.Printf("Skipping line %.0f in %s: %v", lineIndex+1, filename, err)
-			continue
+			return err
 		}
-		points = append(points, point)
-		lineIndex++
+		defer func() {
+			if cerr := stop(); cerr != nil && err == nil {
+				err = fmt.Errorf("write CPU profile: %w", cerr)
+			}
+		}()
 	}
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("scan file: %w", err)
+	if cfg.Profile {
+		cfg.Timings = newStageTimes()
+		defer cfg.Timings.report()
+	}
+	if cfg.Font != "" {
+		if err := loadFont(cfg.Font); err != nil {
+			return fmt.Errorf("-font: %w", err)
+		}
+	}
+	switch {
+	case cfg.Watch:
+		return watch(cfg)
+	case cfg.Follow:
+		return follow(cfg)
+	case cfg.Interactive:
+		return interactive(cfg)
+	case cfg.Animate != "":
+		return animate(cfg)
 	}
-	return points, nil
+	return render(cfg)
 }
 
-// parseLine attempts to parse one line of text into either:
-//
-//	(1) a single float (treated as Y, with X = lineIndex), or
-//	(2) two floats (treated as X and Y).
-func parseLine(line string, lineIndex float64) (Point, error) {
-	fields := strings.Fields(line)
+// render orchestrates reading the data files, creating a plot, and optionally
+// displaying the resulting image in the terminal if it supports graphics.
+func render(cfg Config) error {
+	sets, err := readInputs(cfg)
+	if err != nil {
+		return err
+	}
+	if err := printReports(sets, cfg); err != nil {
+		return err
+	}
+	if cfg.DryRun {
+		return nil
+	}
+	return renderDatasets(sets, cfg)
+}
 
-	switch len(fields) {
-	case 1:
-		// One field => interpret as Y, with X = lineIndex
-		y, err := strconv.ParseFloat(fields[0], 64)
-		if err != nil {
-			return Point{}, fmt.Errorf("invalid Y value %q", fields[0])
+// printReports writes the summary statistics and the text table of the data
+// in sets to standard output, as requested by cfg.PrintStats and cfg.ASCII.
+func printReports(sets []Dataset, cfg Config) error {
+	if cfg.PrintStats {
+		if err := printStats(os.Stdout, combineDatasets(sets, cfg), cfg.StatsFormat); err != nil {
+			return fmt.Errorf("print statistics: %w", err)
 		}
-		return Point{X: lineIndex, Y: y}, nil
+	}
+	if cfg.ASCII {
+		if err := printTable(os.Stdout, combineDatasets(sets, cfg), cfg); err != nil {
+			return fmt.Errorf("print table: %w", err)
+		}
+	}
+	return nil
+}
 
-	case 2:
-		// Two fields => interpret as (X, Y)
-		x, err := strconv.ParseFloat(fields[0], 64)
+// readInputs reads the data of every file in cfg.Inputs, reporting skipped
+// lines and, with cfg.DryRun, the number of points read. An input without any
+// valid points is an error.
+func readInputs(cfg Config) ([]Dataset, error) {
+	sets := make([]Dataset, len(cfg.Inputs))
+	for i, input := range cfg.Inputs {
+		start := time.Now()
+		data, err := readData(input, cfg)
 		if err != nil {
-			return Point{}, fmt.Errorf("invalid X value %q", fields[0])
+			return nil, fmt.Errorf("reading data from %q: %w", input, err)
 		}
-		y, err := strconv.ParseFloat(fields[1], 64)
-		if err != nil {
-			return Point{}, fmt.Errorf("invalid Y value %q", fields[1])
+		logVerbose(cfg, "Read %d points in %d series from %s in %v",
+			countPoints(data), len(data.Series), input, time.Since(start).Round(time.Millisecond))
+		if data.Skipped > 0 || data.NonFinite > 0 {
+			log.Print(skipSummary(input, data))
 		}
-		return Point{X: x, Y: y}, nil
-
-	default:
-		// More than 2 fields or 0 => not supported
-		return Point{}, fmt.Errorf("expected 1 or 2 values, got %d", len(fields))
+		if len(data.Series) == 0 {
+			return nil, fmt.Errorf("no valid data points found in %q", input)
+		}
+		if cfg.DryRun {
+			logInfo(cfg, "%s: %d points in %d series", input, countPoints(data), len(data.Series))
+		}
+		sets[i] = data
 	}
+	return sets, nil
 }
 
-// -----------------------------------------------------------------------------
-// Creating and Saving the Plot
-// -----------------------------------------------------------------------------
+// combineDatasets merges the datasets read from cfg.Inputs into the one that
+// is plotted, applying the legend labels given in cfg.Labels.
+func combineDatasets(sets []Dataset, cfg Config) Dataset {
+	data := mergeDatasets(cfg.Inputs, sets)
 
-// createPlot builds a PNG plot from the data points and saves it to outFile.
-func createPlot(points []Point, outFile string, cfg Config) error {
-	p := plot.New()
-	p.Title.Text = "Data Plot"
-	p.X.Label.Text = "X"
-	p.Y.Label.Text = "Y"
+	// Explicit labels replace the names derived from the data
+	for i, label := range cfg.Labels {
+		if i < len(data.Series) && label != "" {
+			data.Series[i].Label = label
+		}
+	}
+	return data
+}
 
-	// Set background color
-	p.BackgroundColor = cfg.Colors.Background
+// renderDatasets plots the datasets read from cfg.Inputs, saves the image, and
+// displays it in the terminal.
+func renderDatasets(sets []Dataset, cfg Config) error {
+	data := combineDatasets(sets, cfg)
 
-	// Convert our []Point slice into a plotter.XYs
-	pts := make(plotter.XYs, len(points))
-	for i, pt := range points {
-		pts[i].X = pt.X
-		pts[i].Y = pt.Y
+	// Name the output files after the first input, one per format
+	var outFiles []string
+	for _, format := range strings.Split(cfg.Format, ",") {
+		outFiles = append(outFiles, outputName(cfg.Inputs[0], format, cfg))
+		if cfg.Output != "" {
+			break
+		}
 	}
 
-	line, scatter, err := createPlotters(pts, cfg)
-	if err != nil {
-		return fmt.Errorf("creating plotters: %w", err)
+	if cfg.NoFile {
+		// The plot is only rendered in memory for display
+		outFiles = nil
+	} else {
+		// Create the output directory if needed, e.g. for "-o plots/run1.png"
+		if dir := filepath.Dir(outFiles[0]); dir != "." {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return fmt.Errorf("creating output directory %q: %w", dir, err)
+			}
+		}
 	}
 
-	// Add the line and scatter plotter to the plot
-	p.Add(line, scatter)
+	start := time.Now()
+	var img image.Image
+	var err error
+	if cfg.GridRows > 0 {
+		if img, err = createGrid(sets, outFiles, cfg); err != nil {
+			return fmt.Errorf("creating grid: %w", err)
+		}
+	} else if img, err = createPlot(data, outFiles, cfg); err != nil {
+		return fmt.Errorf("creating plot: %w", err)
+	}
+	logVerbose(cfg, "Plotted %d points in %v", countPoints(data), time.Since(start).Round(time.Millisecond))
+	if !cfg.NoFile {
+		logInfo(cfg, "Plot saved to: %s", strings.Join(outFiles, ", "))
+	}
 
-	// Save the plot as PNG with the given width/height
-	if err := p.Save(vg.Points(float64(cfg.Width)), vg.Points(float64(cfg.Height)), outFile); err != nil {
-		return fmt.Errorf("save plot: %w", err)
+	// Attempt to display the plot in the terminal, preferring a saved PNG
+	// for the protocols that pass the file on as it is
+	var displayFile string
+	for _, f := range outFiles {
+		if strings.EqualFold(filepath.Ext(f), ".png") {
+			displayFile = f
+			break
+		}
 	}
+	start = time.Now()
+	if err := displayImage(displayFile, img, data, cfg); err != nil {
+		return fmt.Errorf("displaying plot: %w", err)
+	}
+	cfg.Timings.since("display", start)
+	logVerbose(cfg, "Displayed plot in %v", time.Since(start).Round(time.Millisecond))
 	return nil
 }
 
-// createPlotters initializes a line and scatter plotter with appropriate colors
-// and line width.
-func createPlotters(pts plotter.XYs, cfg Config) (*plotter.Line, *plotter.Scatter, error) {
-	// Create a line plotter
-	line, err := plotter.NewLine(pts)
-	if err != nil {
-		return nil, nil, fmt.Errorf("create line plotter: %w", err)
+// outputName returns the file the plot of input is saved to in the given
+// format: cfg.Output if set, or else the input name with its extension (and
+// any ".gz") replaced as described at derivedName, e.g. "data.dat.gz" =>
+// "data_plot.png". A URL input is named after the last element of its path,
+// in the current directory. With cfg.OutDir the file is placed in that
+// directory instead, below the input's own directory when cfg.MirrorDirs is
+// set and the input path is relative.
+func outputName(input, format string, cfg Config) string {
+	name := cfg.Output
+	if name == "" {
+		if isURL(input) {
+			input = urlFileName(input)
+		}
+		input = strings.TrimSuffix(input, ".gz")
+		base := strings.TrimSuffix(input, filepath.Ext(input))
+		if input == "-" {
+			base = "stdin"
+		}
+		name = derivedName(base, strings.ToLower(format), cfg)
 	}
-	line.Color = cfg.Colors.Line
-	line.Width = vg.Points(cfg.LineWidth) // Apply line width
 
-	// Create a scatter plotter
-	scatter, err := plotter.NewScatter(pts)
-	if err != nil {
-		return nil, nil, fmt.Errorf("create scatter plotter: %w", err)
+	switch {
+	case cfg.OutDir == "" || filepath.IsAbs(name):
+		return name
+	case cfg.MirrorDirs && filepath.IsLocal(name):
+		return filepath.Join(cfg.OutDir, name)
 	}
-	scatter.GlyphStyle.Color = cfg.Colors.Scatter
-	scatter.GlyphStyle.Radius = 2
+	return filepath.Join(cfg.OutDir, filepath.Base(name))
+}
 
-	return line, scatter, nil
+// derivedName returns the output name for an input named base, without its
+// extension, and the format extension ext: base followed by cfg.Suffix, or
+// the expansion of cfg.NameTemplate. The name always ends in ".ext", so that
+// the saved format follows from it.
+func derivedName(base, ext string, cfg Config) string {
+	name := base + cfg.Suffix
+	if cfg.NameTemplate != "" {
+		now := time.Now()
+		name = strings.NewReplacer(
+			"{name}", base,
+			"{ext}", ext,
+			"{date}", now.Format(time.DateOnly),
+			"{time}", now.Format("150405"),
+		).Replace(cfg.NameTemplate)
+	}
+	if !strings.HasSuffix(name, "."+ext) {
+		name += "." + ext
+	}
+	return name
 }
 
-// -----------------------------------------------------------------------------
-// SIXEL Display
-// -----------------------------------------------------------------------------
+// checkNameTemplate reports placeholders of a -name-template that are unknown
+// or not closed.
+func checkNameTemplate(tmpl string) error {
+	for rest := tmpl; ; {
+		i := strings.IndexByte(rest, '{')
+		if i < 0 {
+			return nil
+		}
+		j := strings.IndexByte(rest[i:], '}')
+		if j < 0 {
+			return fmt.Errorf("-name-template %q has an unclosed {", tmpl)
+		}
+		switch field := rest[i : i+j+1]; field {
+		case "{name}", "{ext}", "{date}", "{time}":
+		default:
+			return fmt.Errorf("-name-template %q has unknown field %s; expected {name}, {ext}, {date}, or {time}", tmpl, field)
+		}
+		rest = rest[i+j+1:]
+	}
+}
 
-// displaySixel attempts to display the resulting plot via SIXEL,
-// adjusting image size if the user has specified a scale factor.
-func displaySixel(filename string, cfg Config) error {
-	if !isSixelSupported() {
-		return nil
+// logInfo logs an informational message unless cfg.Quiet is set.
+func logInfo(cfg Config, format string, args ...any) {
+	if !cfg.Quiet {
+		log.Printf(format, args...)
 	}
+}
 
-	imgFile, err := os.Open(filename)
-	if err != nil {
-		return fmt.Errorf("open image file: %w", err)
+// logVerbose logs a diagnostic message when cfg.Verbose is set.
+func logVerbose(cfg Config, format string, args ...any) {
+	if cfg.Verbose {
+		log.Printf(format, args...)
 	}
-	defer imgFile.Close()
+}
 
-	img, _, err := image.Decode(imgFile)
-	if err != nil {
-		return fmt.Errorf("decode image: %w", err)
+// countPoints returns the total number of points in the series of data.
+func countPoints(data Dataset) int {
+	var n int
+	for _, s := range data.Series {
+		n += len(s.Points)
 	}
+	return n
+}
 
-	enc := sixel.NewEncoder(os.Stdout)
-	if cfg.Scale != 1.0 {
-		enc.Width = int(float64(cfg.Width) * cfg.Scale)
-		enc.Height = int(float64(cfg.Height) * cfg.Scale)
+// watch renders the plot, then polls the input files and renders again each
+// time one of them is modified, replacing the previous image in the terminal.
+// Errors while rendering are logged rather than fatal, and a file that is
+// briefly missing (e.g. while being rewritten) is simply waited for.
+func watch(cfg Config) error {
+	var last []time.Time
+	for ; ; time.Sleep(cfg.Interval) {
+		mtimes, err := modTimes(cfg.Inputs)
+		if err != nil || slices.Equal(mtimes, last) {
+			continue
+		}
+		last = mtimes
+
+		clearScreen()
+		if err := render(cfg); err != nil {
+			log.Print(err)
+		}
 	}
+}
 
-	if err := enc.Encode(img); err != nil {
-		return fmt.Errorf("encode SIXEL: %w", err)
+// followedLine is a line read from the input with the given index.
+type followedLine struct {
+	input int
+	text  string
+}
+
+// follow reads the inputs like tail -f, parsing lines as they are appended,
+// and re-renders the growing plot at most once per cfg.Interval. Files are
+// treated as append-only and are never read to completion; standard input is
+// read until it is closed, after which a final plot is rendered.
+func follow(cfg Config) error {
+	parsers := make([]*lineParser, len(cfg.Inputs))
+	for i, input := range cfg.Inputs {
+		parser, err := newLineParser(input, cfg)
+		if err != nil {
+			return err
+		}
+		parsers[i] = parser
+	}
+
+	lines := make(chan followedLine)
+	done := make(chan error)
+	for i, input := range cfg.Inputs {
+		go func() {
+			done <- followInput(i, input, lines)
+		}()
+	}
+
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	var (
+		dirty   bool
+		running = len(cfg.Inputs)
+	)
+	for running > 0 {
+		select {
+		case l := <-lines:
+			if err := parsers[l.input].parse(l.text); err != nil {
+				return fmt.Errorf("reading data from %q: %w", cfg.Inputs[l.input], err)
+			}
+			dirty = true
+
+		case err := <-done:
+			if err != nil {
+				return err
+			}
+			running--
+
+		case <-ticker.C:
+			if dirty {
+				dirty = false
+				if err := renderFollowed(parsers, cfg); err != nil {
+					log.Print(err)
+				}
+			}
+		}
+	}
+	return renderFollowed(parsers, cfg)
+}
+
+// renderFollowed redraws the plot from the data parsed so far, once every
+// input has produced at least one point.
+func renderFollowed(parsers []*lineParser, cfg Config) error {
+	sets := make([]Dataset, len(parsers))
+	for i, parser := range parsers {
+		if len(parser.data.Series) == 0 {
+			return nil
+		}
+		sets[i] = parser.data
+	}
+	clearScreen()
+	for _, parser := range parsers {
+		if parser.data.Skipped > 0 || parser.data.NonFinite > 0 {
+			log.Print(skipSummary(parser.filename, parser.data))
+		}
+	}
+	return renderDatasets(sets, cfg)
+}
+
+// followInput sends each line of the named input to lines, tagged with index.
+// Files are followed past their current end as they grow, so this only
+// returns for standard input, at end of input, or on error.
+func followInput(index int, filename string, lines chan<- followedLine) error {
+	var r io.Reader = os.Stdin
+	if filename != "-" {
+		file, err := os.Open(filename)
+		if err != nil {
+			return fmt.Errorf("open file: %w", err)
+		}
+		defer file.Close()
+		r = tailReader{file}
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines <- followedLine{input: index, text: scanner.Text()}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading data from %q: %w", filename, err)
 	}
 	return nil
 }
 
-// isSixelSupported checks for a terminal type known to support SIXEL.
-func isSixelSupported() bool {
+// tailReader reads a file that is being appended to. At the end of the file
+// it waits for more data instead of returning io.EOF, remembering its offset
+// through the file position.
+type tailReader struct {
+	file *os.File
+}
+
+func (t tailReader) Read(p []byte) (int, error) {
+	for {
+		n, err := t.file.Read(p)
+		if n > 0 || err != io.EOF {
+			return n, err
+		}
+		time.Sleep(followPollInterval)
+	}
+}
+
+// modTimes returns the modification time of each file.
+func modTimes(filenames []string) ([]time.Time, error) {
+	mtimes := make([]time.Time, len(filenames))
+	for i, name := range filenames {
+		info, err := os.Stat(name)
+		if err != nil {
+			return nil, err
+		}
+		mtimes[i] = info.ModTime()
+	}
+	return mtimes, nil
+}
+
+// validateConfig reports options that are invalid or conflict with each other.
+func validateConfig(cfg Config) error {
+	stdin := 0
+	for _, input := range cfg.Inputs {
+		if input == "-" {
+			stdin++
+		}
+	}
+	if stdin > 1 {
+		return fmt.Errorf("standard input (-) can only be read once")
+	}
+	if stdin > 0 && cfg.Watch {
+		return fmt.Errorf("-watch cannot be used with standard input")
+	}
+	for _, input := range cfg.Inputs {
+		if isURL(input) && (cfg.Watch || cfg.Follow) {
+			return fmt.Errorf("-watch and -follow cannot be used with URL input %q", input)
+		}
+	}
+	if n := cfg.GridRows * cfg.GridCols; n > 0 && n < len(cfg.Inputs) {
+		return fmt.Errorf("-grid %dx%d has %d panels for %d inputs", cfg.GridRows, cfg.GridCols, n, len(cfg.Inputs))
+	}
+	if cfg.Equal && (cfg.LogX || cfg.LogY || cfg.Bar || cfg.Hist || cfg.XTime != "" || cfg.Y2Series != nil) {
+		return fmt.Errorf("-equal needs linear X and Y axes in the same units")
+	}
+	if cfg.Quiet && cfg.Verbose {
+		return fmt.Errorf("-quiet and -verbose cannot be used together")
+	}
+	for _, input := range cfg.Inputs {
+		if inputFormat(input, cfg) != "json" {
+			continue
+		}
+		if cfg.Follow || cfg.Bar || cfg.ErrorBars || cfg.Colormap != "" || cfg.XTime != "" || cfg.XCol == "none" {
+			return fmt.Errorf("-follow, -bar, -errorbars, -colormap, -xtime, and -xcol none need line-based input, not JSON file %q", input)
+		}
+		// JSON elements are points rather than lines of fields, so the
+		// options that pick lines and fields do not apply to them
+		if cfg.Columns != nil || cfg.UseCols != nil || cfg.XName != "" || cfg.YNames != nil || cfg.Skip > 0 || cfg.SkipCols > 0 || cfg.Stream {
+			return fmt.Errorf("-columns, -usecols, -x, -y, -skip, -skip-cols, and -stream apply to line-based input, not JSON file %q", input)
+		}
+	}
+	if cfg.DryRun && (cfg.Watch || cfg.Follow) {
+		return fmt.Errorf("-dry-run cannot be used with -watch or -follow")
+	}
+	if (cfg.Profile || cfg.CPUProfile != "") && (cfg.Watch || cfg.Follow) {
+		return fmt.Errorf("-profile and -cpuprofile cannot be used with -watch or -follow")
+	}
+	if cfg.Watch && cfg.Follow {
+		return fmt.Errorf("-watch and -follow cannot be used together")
+	}
+	if cfg.Interactive {
+		switch {
+		case stdin > 0:
+			return fmt.Errorf("-interactive reads keys from standard input, so it cannot also read data from it")
+		case cfg.Watch || cfg.Follow || cfg.DryRun:
+			return fmt.Errorf("-interactive cannot be used with -watch, -follow, or -dry-run")
+		case cfg.GridRows > 0 || cfg.Hist || cfg.Bar || cfg.Box:
+			return fmt.Errorf("-interactive cannot be used with -grid, -hist, -bar, or -box")
+		case cfg.Output != "" || cfg.OutDir != "":
+			return fmt.Errorf("-interactive does not save files, so it cannot be used with -o or -outdir")
+		}
+	}
+	if cfg.Animate != "" {
+		switch {
+		case !strings.EqualFold(filepath.Ext(cfg.Animate), ".gif"):
+			return fmt.Errorf("-animate writes a GIF, so its name must end in .gif, got %q", cfg.Animate)
+		case cfg.Watch || cfg.Follow || cfg.Interactive || cfg.DryRun:
+			return fmt.Errorf("-animate cannot be used with -watch, -follow, -interactive, or -dry-run")
+		case cfg.GridRows > 0 || cfg.Transparent:
+			return fmt.Errorf("-animate cannot be used with -grid or -transparent")
+		case cfg.Output != "" || cfg.OutDir != "":
+			return fmt.Errorf("-animate names its output, so it cannot be used with -o or -outdir")
+		case cfg.FrameDelay < 10*time.Millisecond:
+			return fmt.Errorf("-frame-delay must be at least 10ms, got %v", cfg.FrameDelay)
+		}
+	}
+	if cfg.Interval <= 0 {
+		return fmt.Errorf("-interval must be positive, got %v", cfg.Interval)
+	}
+	if cfg.NoFile && (cfg.Output != "" || cfg.OutDir != "") {
+		return fmt.Errorf("-no-file cannot be used with -o or -outdir")
+	}
+	if cfg.MirrorDirs && cfg.OutDir == "" {
+		return fmt.Errorf("-mirror-dirs needs -outdir")
+	}
+	if cfg.NameTemplate != "" {
+		if cfg.Output != "" || cfg.Suffix != defaultSuffix {
+			return fmt.Errorf("-name-template cannot be used with -o or -suffix")
+		}
+		if err := checkNameTemplate(cfg.NameTemplate); err != nil {
+			return err
+		}
+	}
+	if cfg.Output == "" {
+		// Every derived name needs more to it than the extension
+		for _, input := range cfg.Inputs {
+			for _, format := range strings.Split(cfg.Format, ",") {
+				name := outputName(input, format, cfg)
+				if strings.TrimSuffix(filepath.Base(name), filepath.Ext(name)) == "" {
+					return fmt.Errorf("output name %q for %q has no file name before the extension", name, input)
+				}
+			}
+		}
+	}
+	if cfg.Output != "" {
+		ext := strings.TrimPrefix(filepath.Ext(cfg.Output), ".")
+		if _, ok := imageFormats[strings.ToLower(ext)]; !ok {
+			return fmt.Errorf("unsupported output format %q for %q", ext, cfg.Output)
+		}
+	} else {
+		for _, format := range strings.Split(cfg.Format, ",") {
+			if _, ok := imageFormats[strings.ToLower(format)]; !ok {
+				return fmt.Errorf("unsupported output format %q", format)
+			}
+		}
+	}
+	if cfg.Transparent {
+		formats := strings.Split(cfg.Format, ",")
+		if cfg.Output != "" {
+			formats = []string{strings.TrimPrefix(filepath.Ext(cfg.Output), ".")}
+		}
+		for _, format := range formats {
+			if f := strings.ToLower(format); f == "jpg" || f == "jpeg" {
+				return fmt.Errorf("-transparent cannot be used with JPEG output, which has no alpha channel")
+			}
+		}
+	}
+	if cfg.CMYK {
+		formats := strings.Split(cfg.Format, ",")
+		if cfg.Output != "" {
+			formats = []string{strings.TrimPrefix(filepath.Ext(cfg.Output), ".")}
+		}
+		if !slices.ContainsFunc(formats, func(f string) bool { return strings.ToLower(f) == "pdf" }) {
+			return fmt.Errorf("-cmyk only applies to PDF output; add pdf to -format or use -o with a .pdf name")
+		}
+	}
+	if cfg.NoPoints && cfg.ScatterOnly {
+		return fmt.Errorf("-no-points and -scatter-only cannot be used together")
+	}
+	if cfg.Smooth != 0 && (cfg.Smooth < 3 || cfg.Smooth%2 == 0) {
+		return fmt.Errorf("-smooth window must be an odd number >= 3, got %d", cfg.Smooth)
+	}
+	if cfg.SmoothMedian != 0 && cfg.SmoothMedian < 2 {
+		return fmt.Errorf("-smooth-median window must be >= 2, got %d", cfg.SmoothMedian)
+	}
+	if cfg.Smooth != 0 && cfg.SmoothMedian != 0 {
+		return fmt.Errorf("-smooth and -smooth-median cannot be used together")
+	}
+	if cfg.ScatterLimit < 0 {
+		return fmt.Errorf("-scatter-threshold must not be negative, got %d", cfg.ScatterLimit)
+	}
+	if cfg.MaxPoints != 0 && cfg.MaxPoints < 3 {
+		return fmt.Errorf("-max-points must be at least 3, got %d", cfg.MaxPoints)
+	}
+	if cfg.Stream && (cfg.Hist || cfg.Bar) {
+		return fmt.Errorf("-stream cannot be used with -hist or -bar, which need every value")
+	}
+	if cfg.FillOpacity < 0 || cfg.FillOpacity > 1 {
+		return fmt.Errorf("-fill-opacity must be between 0 and 1, got %g", cfg.FillOpacity)
+	}
+	if cfg.Fill && cfg.LogY {
+		return fmt.Errorf("-fill cannot be used with -logy, which has no Y = 0")
+	}
+	if cfg.Bins < 0 {
+		return fmt.Errorf("-bins must not be negative, got %d", cfg.Bins)
+	}
+	if cfg.Hist && (cfg.LogX || cfg.LogY || cfg.Fit != "") {
+		return fmt.Errorf("-hist cannot be used with -logx, -logy, or -fit")
+	}
+	if cfg.ClipOutliers && cfg.Hist {
+		return fmt.Errorf("-clip-outliers cannot be used with -hist")
+	}
+	if cfg.Clip && math.IsNaN(cfg.XMin) && math.IsNaN(cfg.XMax) && math.IsNaN(cfg.YMin) && math.IsNaN(cfg.YMax) {
+		return fmt.Errorf("-clip needs at least one of -xmin, -xmax, -ymin, or -ymax")
+	}
+	if cfg.Transpose && (cfg.Hist || cfg.Bar || cfg.ErrorBars || cfg.XTime != "" || cfg.Y2Series != nil) {
+		return fmt.Errorf("-transpose cannot be used with -hist, -bar, -errorbars, -xtime, or -y2-series")
+	}
+	if cfg.Colormap != "" {
+		if _, ok := colormaps[cfg.Colormap]; !ok {
+			return fmt.Errorf("unknown colormap %q", cfg.Colormap)
+		}
+		if cfg.ErrorBars || cfg.Hist || cfg.Bar || cfg.NoPoints {
+			return fmt.Errorf("-colormap colors scatter points and cannot be used with -errorbars, -hist, -bar, or -no-points")
+		}
+	}
+	if cfg.Bar && (cfg.Hist || cfg.LogX || cfg.Fit != "" || cfg.ErrorBars) {
+		return fmt.Errorf("-bar cannot be used with -hist, -logx, -fit, or -errorbars")
+	}
+	if cfg.Box && (cfg.Hist || cfg.Bar || cfg.LogX || cfg.Fit != "" || cfg.ErrorBars || cfg.Colormap != "" || cfg.XTime != "" || cfg.Transpose || cfg.Stats != "" || cfg.Y2Series != nil || cfg.Equal || cfg.Stream) {
+		return fmt.Errorf("-box cannot be used with -hist, -bar, -logx, -fit, -errorbars, -colormap, -xtime, -transpose, -stats, -y2-series, -equal, or -stream")
+	}
+	if cfg.Y2Series != nil && (cfg.LogY || cfg.Hist || cfg.Bar || cfg.Fit != "") {
+		return fmt.Errorf("-y2-series cannot be used with -logy, -hist, -bar, or -fit")
+	}
+	if cfg.XTime != "" && (cfg.Bar || cfg.LogX) {
+		return fmt.Errorf("-xtime cannot be used with -bar or -logx")
+	}
+	if cfg.Band != nil && (cfg.Hist || cfg.Bar || cfg.Box || cfg.Transpose || cfg.ErrorBars || cfg.Colormap != "" || cfg.Y2Series != nil) {
+		return fmt.Errorf("-band cannot be used with -hist, -bar, -box, -transpose, -errorbars, -colormap, or -y2-series")
+	}
+	if (cfg.XName != "" || cfg.YNames != nil) && (cfg.UseCols != nil || cfg.Columns != nil) {
+		return fmt.Errorf("-x and -y cannot be used with -usecols or -columns")
+	}
+	if cfg.UseCols != nil && cfg.Columns != nil {
+		return fmt.Errorf("-usecols and -columns cannot be used together")
+	}
+	for _, c := range cfg.Columns {
+		if c < 0 {
+			return fmt.Errorf("-columns index %d must be >= 0", c)
+		}
+	}
+	switch cfg.XCol {
+	case "first":
+		if slices.Contains(cfg.Columns, 0) {
+			return fmt.Errorf("-columns index 0 is the X field; use -xcol none to plot it as Y")
+		}
+	case "none":
+		if cfg.XName != "" || cfg.YNames != nil || cfg.UseCols != nil || cfg.XTime != "" || cfg.Bar || cfg.Box {
+			return fmt.Errorf("-xcol none cannot be used with -x, -y, -usecols, -xtime, -bar, or -box, which read X or categories from a field")
+		}
+	default:
+		return fmt.Errorf(`invalid -xcol %q: must be "first" or "none"`, cfg.XCol)
+	}
+	if cfg.Margin < 0 || math.IsInf(cfg.Margin, 0) {
+		return fmt.Errorf("-margin must be a non-negative number of points, got %v", cfg.Margin)
+	}
+	if cfg.DPI <= 0 {
+		return fmt.Errorf("-dpi must be positive, got %d", cfg.DPI)
+	}
+	if cfg.Skip < 0 {
+		return fmt.Errorf("-skip must not be negative, got %d", cfg.Skip)
+	}
+	if cfg.SkipCols < 0 {
+		return fmt.Errorf("-skip-cols must not be negative, got %d", cfg.SkipCols)
+	}
+	if cfg.XMin >= cfg.XMax {
+		return fmt.Errorf("-xmin (%g) must be less than -xmax (%g)", cfg.XMin, cfg.XMax)
+	}
+	if cfg.YMin >= cfg.YMax {
+		return fmt.Errorf("-ymin (%g) must be less than -ymax (%g)", cfg.YMin, cfg.YMax)
+	}
+	switch cfg.Protocol {
+	case "auto", "sixel", "kitty", "iterm", "text", "none":
+	default:
+		return fmt.Errorf(`-protocol must be "auto", "sixel", "kitty", "iterm", "text", or "none", got %q`, cfg.Protocol)
+	}
+	switch cfg.Sixel {
+	case "auto", "always", "never":
+	default:
+		return fmt.Errorf(`-sixel must be "auto", "always", or "never", got %q`, cfg.Sixel)
+	}
+	if _, ok := lineStyles[cfg.LineStyle]; !ok && cfg.LineStyle != "cycle" {
+		return fmt.Errorf("unknown line style %q", cfg.LineStyle)
+	}
+	if _, ok := markers[cfg.Marker]; !ok && cfg.Marker != "cycle" {
+		return fmt.Errorf("unknown marker %q", cfg.Marker)
+	}
+	if cfg.MarkerSize <= 0 {
+		return fmt.Errorf("-marker-size must be positive, got %g", cfg.MarkerSize)
+	}
+	for _, size := range []struct {
+		flag  string
+		value float64
+	}{{"font-size", cfg.FontSize}, {"title-size", cfg.TitleSize}, {"tick-size", cfg.TickSize}} {
+		if size.value < 0 {
+			return fmt.Errorf("-%s must not be negative, got %g", size.flag, size.value)
+		}
+	}
+	if _, ok := themes[cfg.Theme]; !ok {
+		return fmt.Errorf(`-theme must be "light" or "dark", got %q`, cfg.Theme)
+	}
+	if _, ok := palettes[cfg.Palette]; !ok {
+		return fmt.Errorf("unknown palette %q", cfg.Palette)
+	}
+	switch cfg.Header {
+	case "auto", "always", "never":
+	default:
+		return fmt.Errorf(`-header must be "auto", "always", or "never", got %q`, cfg.Header)
+	}
+	switch cfg.NaN {
+	case "skip", "gap", "error":
+	default:
+		return fmt.Errorf(`-nan must be "skip", "gap", or "error", got %q`, cfg.NaN)
+	}
+	switch cfg.InFormat {
+	case "auto", "whitespace", "csv", "tsv", "json":
+	default:
+		return fmt.Errorf(`-in-format must be "auto", "whitespace", "csv", "tsv", or "json", got %q`, cfg.InFormat)
+	}
+	if cfg.InFormat != "auto" && cfg.Delimiter != "auto" {
+		return fmt.Errorf("-in-format and -delimiter cannot be used together")
+	}
+	switch cfg.Decimal {
+	case ".", ",":
+	default:
+		return fmt.Errorf(`-decimal must be "." or ",", got %q`, cfg.Decimal)
+	}
+	if delim, _, err := parseDelimiter(cfg.Delimiter); err == nil && delim == ',' && cfg.Decimal == "," {
+		return fmt.Errorf("-decimal , cannot be used with a comma -delimiter")
+	}
+	switch cfg.LegendPos {
+	case "top-left", "top-right", "bottom-left", "bottom-right", "top", "none":
+	default:
+		return fmt.Errorf(`-legend-pos must be "top-left", "top-right", "bottom-left", "bottom-right", "top", or "none", got %q`, cfg.LegendPos)
+	}
+	switch cfg.TickFormat {
+	case "", "plain", "sci", "eng", "comma":
+	default:
+		return fmt.Errorf(`-tick-format must be "plain", "sci", "eng", or "comma", got %q`, cfg.TickFormat)
+	}
+	switch cfg.Step {
+	case "pre", "post", "none":
+	default:
+		return fmt.Errorf(`-step must be "pre", "post", or "none", got %q`, cfg.Step)
+	}
+	if cfg.Fit != "" {
+		if _, _, err := parseFit(cfg.Fit); err != nil {
+			return err
+		}
+	}
+	switch cfg.Stats {
+	case "", "mean", "minmax", "stddev":
+	default:
+		return fmt.Errorf(`-stats must be "mean", "minmax", or "stddev", got %q`, cfg.Stats)
+	}
+	if cfg.Stats != "" && cfg.Hist {
+		return fmt.Errorf("-stats cannot be used with -hist")
+	}
+	switch cfg.StatsFormat {
+	case "table", "json":
+	default:
+		return fmt.Errorf(`-stats-format must be "table" or "json", got %q`, cfg.StatsFormat)
+	}
+	if cfg.PrintStats && (cfg.Follow || cfg.Interactive) {
+		return fmt.Errorf("-print-stats cannot be used with -follow or -interactive")
+	}
+	if cfg.ASCII && (cfg.Follow || cfg.Interactive) {
+		return fmt.Errorf("-ascii cannot be used with -follow or -interactive")
+	}
+	switch cfg.Transform {
+	case "", "cumsum", "diff", "abs", "normalize":
+	default:
+		return fmt.Errorf(`-transform must be "cumsum", "diff", "abs", or "normalize", got %q`, cfg.Transform)
+	}
+	if cfg.Transform != "" && cfg.ErrorBars {
+		return fmt.Errorf("-transform cannot be used with -errorbars")
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Reading Data
+// -----------------------------------------------------------------------------
+
+// maxSkippedLines is the number of skipped line numbers kept for the summary.
+const maxSkippedLines = 5
+
+// readData opens the given file (or stdin for "-") and reads it line by line
+// into a Dataset, each line giving one point of every series: X from its first
+// field, or the line index for a single field, and Y from the rest, followed
+// by their errors with cfg.ErrorBars or their color value with cfg.Colormap.
+// Fields are split on cfg.Delimiter, after dropping the first cfg.SkipCols and
+// keeping only cfg.UseCols, and numbers use cfg.Decimal. Comment lines, blank
+// lines, and the first cfg.Skip data lines are skipped, but metadata
+// directives in comments are collected in the Dataset's Meta. Column names
+// come from a header row, per cfg.Header, or else from the last comment before
+// the data. Non-finite values are handled according to cfg.NaN, and with
+// cfg.Stream each series is downsampled as it is read. JSON input is read by
+// readJSON instead.
+func readData(filename string, cfg Config) (Dataset, error) {
+	defer cfg.Timings.since("read", time.Now())
+	if inputFormat(filename, cfg) == "json" {
+		return readJSON(filename)
+	}
+
+	parser, err := newLineParser(filename, cfg)
+	if err != nil {
+		return Dataset{}, err
+	}
+
+	file, err := openInput(filename)
+	if err != nil {
+		return Dataset{}, err
+	}
+	defer file.Close()
+
+	// Parsing is timed apart from reading only when profiling, since timing
+	// every line has a cost of its own
+	var parsing time.Duration
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var start time.Time
+		if cfg.Timings != nil {
+			start = time.Now()
+		}
+		if err := parser.parse(scanner.Text()); err != nil {
+			return Dataset{}, err
+		}
+		if cfg.Timings != nil {
+			parsing += time.Since(start)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Dataset{}, fmt.Errorf("scan file: %w", err)
+	}
+	cfg.Timings.add("read", -parsing)
+	cfg.Timings.add("parse", parsing)
+	return parser.data, nil
+}
+
+// lineParser converts the lines of one data file into a Dataset one line at
+// a time, so that input can also be parsed as it arrives. The accepted format
+// is described at readData.
+type lineParser struct {
+	filename  string
+	cfg       Config
+	delim     rune
+	detect    bool // Whether the delimiter is still to be sniffed
+	comments  []string
+	data      Dataset
+	header    string   // Most recent comment before the first data line
+	names     []string // Column names from a header row
+	checked   bool     // Whether the first line was checked for a header row
+	trailing  bool     // Whether lines end in a delimiter that closes no field, as in "1,2,"
+	skipped   int      // Leading non-comment lines discarded so far
+	lineIndex float64
+	lineNum   int              // Number of the current line in the file, from 1
+	thinners  []*streamThinner // Per-series downsampling with -stream
+	onSkip    func(ParseError) // Called for each skipped malformed line, if set
+}
+
+// newLineParser returns a parser for the named file using the reading options
+// in cfg.
+func newLineParser(filename string, cfg Config) (*lineParser, error) {
+	if cfg.Delimiter == "auto" {
+		// The input format fixes the delimiter, if it is known
+		switch inputFormat(filename, cfg) {
+		case "whitespace":
+			cfg.Delimiter = "whitespace"
+		case "csv":
+			cfg.Delimiter = "comma"
+			if cfg.Decimal == "," {
+				cfg.Delimiter = "semicolon"
+			}
+		case "tsv":
+			cfg.Delimiter = "tab"
+		}
+	}
+	delim, detect, err := parseDelimiter(cfg.Delimiter)
+	if err != nil {
+		return nil, err
+	}
+	return &lineParser{
+		filename: filename,
+		cfg:      cfg,
+		delim:    delim,
+		detect:   detect,
+		comments: parseCommentPrefixes(cfg.Comment),
+	}, nil
+}
+
+// parse processes one line of input, adding its values to the dataset.
+// Malformed lines are counted and skipped, unless cfg.Strict is set; an error
+// is returned only when reading must stop.
+func (lp *lineParser) parse(line string) error {
+	cfg := lp.cfg
+	lp.lineNum++
+	raw := line // Untrimmed, so that leading and trailing tabs delimit fields
+	line = strings.TrimSpace(line)
+
+	// Ignore empty lines or comment lines
+	if line == "" {
+		return nil
+	}
+	if text, ok := stripComment(line, lp.comments); ok {
+		if key, value, ok := parseDirective(text); ok {
+			// A bad directive is no malformed data line, so it is only
+			// reported, and the plot is drawn without it
+			if err := lp.data.Meta.set(key, value); err != nil {
+				log.Printf("Warning: ignoring line %d of %s: %v", lp.lineNum, lp.filename, err)
+			}
+			return nil
+		}
+		if lp.data.Series == nil {
+			lp.header = text
+		}
+		return nil
+	}
+
+	if lp.skipped < cfg.Skip {
+		lp.skipped++
+		return nil
+	}
+
+	// Sniff the delimiter from the first non-comment line
+	if lp.detect {
+		lp.delim = detectDelimiter(line, cfg.Decimal == ",")
+		lp.detect = false
+	}
+
+	fields := splitFields(raw, lp.delim)
+	// Whether a file ends its lines with a delimiter, as some spreadsheets
+	// write "1,2,", is decided once from its first line, so that elsewhere
+	// a trailing empty field is a missing value like any other
+	if !lp.checked {
+		lp.trailing = endsInEmptyField(fields)
+	}
+	if lp.trailing && endsInEmptyField(fields) {
+		fields = fields[:len(fields)-1]
+	}
+	if cfg.Decimal == "," {
+		normalizeDecimals(fields)
+	}
+	if cfg.SkipCols > 0 {
+		if len(fields) <= cfg.SkipCols {
+			return lp.skipLine(line, fmt.Errorf("expected more than %d fields, got %d", cfg.SkipCols, len(fields)))
+		}
+		fields = fields[cfg.SkipCols:]
+	}
+	if (cfg.XName != "" || cfg.YNames != nil) && cfg.UseCols == nil {
+		// Resolve column names against a header row on this line, or else
+		// the header comment, and read the matching fields from now on
+		names := lp.headerNames()
+		if !lp.checked && lp.isHeaderRow(fields) {
+			names = fields
+		}
+		cols, err := resolveColumns(names, cfg.XName, cfg.YNames)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", lp.lineNum, err)
+		}
+		lp.cfg.UseCols = cols
+		cfg = lp.cfg
+	}
+	if cfg.UseCols != nil {
+		var err error
+		if fields, err = selectFields(fields, cfg.UseCols); err != nil {
+			return lp.skipLine(line, err)
+		}
+	}
+
+	// The first line may be a row of column names
+	if !lp.checked {
+		lp.checked = true
+		if lp.isHeaderRow(fields) {
+			lp.names = fields
+			return nil
+		}
+	}
+
+	var (
+		x        float64
+		ys       []float64
+		category string
+		err      error
+	)
+	columns := cfg.Columns
+	switch {
+	case cfg.Bar:
+		// Bars are placed by line index and labeled by category
+		x = lp.lineIndex
+		category, ys, err = parseBarLine(fields, columns)
+	case cfg.Box && len(fields) > 1, cfg.XCol == "none":
+		// No field is X: every field holds a Y value, or with -box a value
+		// of the sample in its column
+		x = lp.lineIndex
+		columns = sampleColumns(len(fields), columns)
+		ys, err = parseValues(fields, columns)
+	default:
+		x, ys, err = parseLine(fields, lp.lineIndex, columns, cfg.XTime)
+	}
+
+	// With error bars, the values after Y are its errors rather than series
+	var errLow, errHigh float64
+	if err == nil && cfg.ErrorBars {
+		ys, errLow, errHigh, err = splitErrors(ys)
+	}
+	// With a color map, the value after Y is the point's color
+	var z float64
+	if err == nil && cfg.Colormap != "" {
+		ys, z, err = splitColorValue(ys)
+	}
+	if err == nil && lp.data.Series != nil && len(ys) != len(lp.data.Series) {
+		err = fmt.Errorf("expected %d Y values, got %d", len(lp.data.Series), len(ys))
+	}
+	if err != nil {
+		// Count and continue rather than abort on malformed lines
+		return lp.skipLine(line, err)
+	}
+
+	// Non-finite values poison auto-scaling, so drop or reject them
+	// unless they are kept to mark gaps in the line. Values missing from
+	// empty fields are not in the input to reject or report, and are only
+	// dropped or kept as gaps
+	if !isFinite(x) || !allFinite(ys, true) {
+		switch cfg.NaN {
+		case "error":
+			return fmt.Errorf("non-finite value on line %d: %q", lp.lineNum, line)
+		case "skip":
+			lp.data.NonFinite++
+			if len(lp.data.NonFiniteLines) < maxSkippedLines {
+				lp.data.NonFiniteLines = append(lp.data.NonFiniteLines, lp.lineNum)
+			}
+		}
+	}
+
+	// The first valid line fixes the number of series
+	if lp.data.Series == nil {
+		labelCols := columns
+		if len(cfg.UseCols) > 1 {
+			// Name series after their fields in the file, not in the selection
+			labelCols = cfg.UseCols[1:]
+		}
+		lp.data.Series = newSeries(len(ys), labelCols)
+		if lp.names == nil {
+			lp.names = lp.headerNames()
+			if cfg.UseCols != nil {
+				lp.names, _ = selectFields(lp.names, cfg.UseCols)
+			}
+		}
+		applyHeader(&lp.data, lp.names, len(fields), columns, cfg.XCol == "none")
+		if cfg.Stream {
+			target := cfg.MaxPoints
+			if target == 0 {
+				target = defaultStreamPoints
+			}
+			for range ys {
+				lp.thinners = append(lp.thinners, newStreamThinner(target))
+			}
+		}
+	}
+	for i, y := range ys {
+		if cfg.NaN != "gap" && !(isFinite(x) && isFinite(y)) {
+			continue
+		}
+		pt := Point{X: x, Y: y, ErrLow: errLow, ErrHigh: errHigh, Z: z}
+		if cfg.Stream {
+			lp.data.Series[i].Points = lp.thinners[i].add(lp.data.Series[i].Points, pt)
+			continue
+		}
+		lp.data.Series[i].Points = append(lp.data.Series[i].Points, pt)
+	}
+	if cfg.Bar {
+		lp.data.Categories = append(lp.data.Categories, category)
+	}
+	lp.lineIndex++
+	return nil
+}
+
+// headerNames returns the column names in the header comment, without those
+// of the columns dropped by cfg.SkipCols.
+func (lp *lineParser) headerNames() []string {
+	if lp.header == "" {
+		return nil
+	}
+	names := splitFields(lp.header, lp.delim)
+	if lp.trailing && endsInEmptyField(names) {
+		names = names[:len(names)-1]
+	}
+	return names[min(lp.cfg.SkipCols, len(names)):]
+}
+
+// endsInEmptyField reports whether the last of several fields is empty, as
+// after a delimiter at the end of a line.
+func endsInEmptyField(fields []string) bool {
+	return len(fields) > 1 && fields[len(fields)-1] == ""
+}
+
+// isHeaderRow reports whether fields, read from the first data line, are
+// column names according to cfg.Header.
+func (lp *lineParser) isHeaderRow(fields []string) bool {
+	// A bar category or timestamp is never a number, so look past it
+	if (lp.cfg.Bar || lp.cfg.XTime != "") && len(fields) > 1 {
+		fields = fields[1:]
+	}
+	return isHeaderRow(fields, lp.cfg.Header)
+}
+
+// skipLine records that the current line was skipped as malformed. In strict
+// mode it instead returns an error describing the line.
+func (lp *lineParser) skipLine(line string, err error) error {
+	if lp.cfg.Strict {
+		return fmt.Errorf("malformed line %d: %w: %q", lp.lineNum, err, line)
+	}
+	if lp.onSkip != nil {
+		lp.onSkip(ParseError{Line: lp.lineNum, Text: line, Err: err})
+	}
+	if lp.data.Skipped == 0 {
+		lp.data.SkipReason = err.Error()
+	}
+	lp.data.Skipped++
+	if len(lp.data.SkippedLines) < maxSkippedLines {
+		lp.data.SkippedLines = append(lp.data.SkippedLines, lp.lineNum)
+	}
+	return nil
+}
+
+// skipSummary describes the malformed lines skipped while reading data from
+// filename, e.g. "Skipped 12 malformed lines in a.dat (first at 5, 9, 12...)",
+// followed by the reason the first of them was rejected, and the lines whose
+// non-finite values were dropped.
+func skipSummary(filename string, data Dataset) string {
+	if filename == "-" {
+		filename = "stdin"
+	}
+	var parts []string
+	switch {
+	case data.Skipped == 1:
+		parts = append(parts, fmt.Sprintf("Skipped 1 malformed line in %s: line %d: %s",
+			filename, data.SkippedLines[0], data.SkipReason))
+	case data.Skipped > 1:
+		parts = append(parts, fmt.Sprintf("Skipped %d malformed lines in %s (first at %s): line %d: %s",
+			data.Skipped, filename, lineList(data.Skipped, data.SkippedLines), data.SkippedLines[0], data.SkipReason))
+	}
+	switch {
+	case data.NonFinite == 1:
+		parts = append(parts, fmt.Sprintf("Skipped non-finite values on 1 line in %s: line %d",
+			filename, data.NonFiniteLines[0]))
+	case data.NonFinite > 1:
+		parts = append(parts, fmt.Sprintf("Skipped non-finite values on %d lines in %s (first at %s)",
+			data.NonFinite, filename, lineList(data.NonFinite, data.NonFiniteLines)))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// lineList formats the first line numbers nums of count lines as "5, 9, 12",
+// with "..." appended when some were left out.
+func lineList(count int, nums []int) string {
+	strs := make([]string, len(nums))
+	for i, n := range nums {
+		strs[i] = strconv.Itoa(n)
+	}
+	list := strings.Join(strs, ", ")
+	if count > len(nums) {
+		list += "..."
+	}
+	return list
+}
+
+// splitErrors separates the Y errors from the values of a line read with
+// -errorbars: "y err" gives a symmetric error and "y low high" separate errors
+// below and above. Errors are magnitudes and must be finite and non-negative.
+func splitErrors(values []float64) (ys []float64, low, high float64, err error) {
+	switch len(values) {
+	case 2:
+		low, high = values[1], values[1]
+	case 3:
+		low, high = values[1], values[2]
+	default:
+		return nil, 0, 0, fmt.Errorf("expected Y and 1 or 2 error values, got %d values", len(values))
+	}
+	for _, e := range []float64{low, high} {
+		if !isFinite(e) || e < 0 {
+			return nil, 0, 0, fmt.Errorf("invalid error value %g", e)
+		}
+	}
+	return values[:1], low, high, nil
+}
+
+// missingValue is the NaN that parseValues yields for an empty field. Its
+// payload differs from that of math.NaN, which strconv returns for "NaN" in
+// the input, so that cfg.NaN applies only to values actually read as NaN.
+var missingValue = math.Float64frombits(0x7ff8000000000002)
+
+// isMissing reports whether v is missingValue, from an empty field.
+func isMissing(v float64) bool {
+	return math.Float64bits(v) == math.Float64bits(missingValue)
+}
+
+// isFinite reports whether v is neither NaN nor infinite.
+func isFinite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// allFinite reports whether every value in vs is finite or, with
+// allowMissing, missing.
+func allFinite(vs []float64, allowMissing bool) bool {
+	for _, v := range vs {
+		if !isFinite(v) && !(allowMissing && isMissing(v)) {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeDatasets combines the datasets read from several files into one for
+// plotting together. With more than one file, series are named after their
+// file. Axis labels are kept only where all files agree on them.
+func mergeDatasets(names []string, sets []Dataset) Dataset {
+	if len(sets) == 1 {
+		return sets[0]
+	}
+
+	merged := Dataset{XLabel: sets[0].XLabel, YLabel: sets[0].YLabel, Meta: commonMetadata(sets)}
+	for i, data := range sets {
+		if data.XLabel != merged.XLabel {
+			merged.XLabel = ""
+		}
+		if data.YLabel != merged.YLabel {
+			merged.YLabel = ""
+		}
+		if len(data.Categories) > len(merged.Categories) {
+			merged.Categories = data.Categories
+		}
+
+		name := inputBase(names[i])
+		for _, s := range data.Series {
+			if len(data.Series) == 1 {
+				s.Label = name
+			} else {
+				s.Label = name + ": " + s.Label
+			}
+			merged.Series = append(merged.Series, s)
+		}
+	}
+	return merged
+}
+
+// inputBase returns the short name an input is known by in legends and panel
+// titles: "stdin" for "-", the file name of a URL, or the base of a path.
+func inputBase(name string) string {
+	switch {
+	case name == "-":
+		return "stdin"
+	case isURL(name):
+		return urlFileName(name)
+	}
+	return filepath.Base(name)
+}
+
+// newSeries allocates n empty series labeled by their source column index.
+func newSeries(n int, columns []int) []Series {
+	series := make([]Series, n)
+	for i := range series {
+		col := i + 1
+		if len(columns) > 0 {
+			col = columns[i]
+		}
+		series[i].Label = fmt.Sprintf("Column %d", col)
+		series[i].Column = col
+	}
+	return series
+}
+
+// isHeaderRow reports whether the fields of the first data line are column
+// names. In "auto" mode, a line whose first field is not a number is a header.
+func isHeaderRow(fields []string, mode string) bool {
+	switch mode {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if len(fields) == 0 {
+		return false
+	}
+	_, err := strconv.ParseFloat(fields[0], 64)
+	return err != nil
+}
+
+// applyHeader labels the axes and series of data from the column names of a
+// header row or comment. It does nothing unless there is exactly one name per
+// field. With noX, as for -xcol none, every field is a Y column, named by
+// columns.
+func applyHeader(data *Dataset, names []string, numFields int, columns []int, noX bool) {
+	if len(names) != numFields {
+		return
+	}
+	if numFields == 1 && !noX {
+		// Single-column data: the only name describes Y
+		data.YLabel = names[0]
+		data.Series[0].Label = names[0]
+		return
+	}
+
+	if !noX {
+		data.XLabel = names[0]
+	}
+	for i := range data.Series {
+		col := i + 1
+		if len(columns) > 0 {
+			col = columns[i]
+		}
+		data.Series[i].Label = names[col]
+	}
+	if len(data.Series) == 1 {
+		data.YLabel = data.Series[0].Label
+	}
+}
+
+// inputFormat returns the format of the named input: cfg.InFormat unless it is
+// "auto", or else the format implied by the file extension ("json", "csv", or
+// "tsv"), ignoring any ".gz", or "auto" when there is none.
+func inputFormat(filename string, cfg Config) string {
+	if cfg.InFormat != "auto" {
+		return cfg.InFormat
+	}
+	if isURL(filename) {
+		filename = urlFileName(filename)
+	}
+	switch strings.ToLower(filepath.Ext(strings.TrimSuffix(filename, ".gz"))) {
+	case ".json":
+		return "json"
+	case ".csv":
+		return "csv"
+	case ".tsv", ".tab":
+		return "tsv"
+	}
+	return "auto"
+}
+
+// openInput opens the named data file for reading. The name "-" selects
+// standard input, and an HTTP or HTTPS URL is downloaded as it is read.
+// Gzip-compressed input is decompressed transparently.
+func openInput(filename string) (io.ReadCloser, error) {
+	if filename == "-" {
+		return gunzipIfCompressed(io.NopCloser(os.Stdin))
+	}
+	if isURL(filename) {
+		body, err := openURL(filename)
+		if err != nil {
+			return nil, err
+		}
+		return gunzipIfCompressed(body)
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("open file: %w", err)
+	}
+	return gunzipIfCompressed(file)
+}
+
+// readCloser pairs a Reader with the Closer of the underlying input.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// gunzipIfCompressed wraps rc in a gzip reader when its data starts with the
+// gzip magic number, so that compressed files are detected by content rather
+// than by name.
+func gunzipIfCompressed(rc io.ReadCloser) (io.ReadCloser, error) {
+	br := bufio.NewReader(rc)
+	if magic, _ := br.Peek(2); !bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		return readCloser{br, rc}, nil
+	}
+
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		rc.Close()
+		return nil, fmt.Errorf("open gzip stream: %w", err)
+	}
+	return readCloser{zr, rc}, nil
+}
+
+// parseCommentPrefixes splits the -comment flag value into comment prefixes.
+// A value containing commas is a list of prefixes, such as "//,;"; otherwise
+// each character is a prefix of its own, so "#%" means '#' or '%'.
+func parseCommentPrefixes(s string) []string {
+	if strings.Contains(s, ",") {
+		var prefixes []string
+		for _, p := range strings.Split(s, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				prefixes = append(prefixes, p)
+			}
+		}
+		return prefixes
+	}
+
+	prefixes := make([]string, 0, len(s))
+	for _, r := range s {
+		prefixes = append(prefixes, string(r))
+	}
+	return prefixes
+}
+
+// stripComment reports whether line starts with one of the comment prefixes
+// and, if so, returns the comment text after it.
+func stripComment(line string, prefixes []string) (string, bool) {
+	for _, p := range prefixes {
+		if strings.HasPrefix(line, p) {
+			return strings.TrimSpace(line[len(p):]), true
+		}
+	}
+	return "", false
+}
+
+// parseDelimiter converts the -delimiter flag value into a separator rune.
+// A zero rune means "split on runs of whitespace". The detect result is true
+// when the delimiter should be sniffed from the data instead.
+func parseDelimiter(s string) (delim rune, detect bool, err error) {
+	switch strings.ToLower(s) {
+	case "", "auto":
+		return 0, true, nil
+	case "whitespace", "space":
+		return 0, false, nil
+	case "tab", `\t`:
+		return '\t', false, nil
+	case "comma":
+		return ',', false, nil
+	case "semicolon":
+		return ';', false, nil
+	}
+	if r := []rune(s); len(r) == 1 {
+		return r[0], false, nil
+	}
+	return 0, false, fmt.Errorf("invalid delimiter %q", s)
+}
+
+// detectDelimiter guesses the field delimiter of a data line, preferring
+// commas, then tabs, then semicolons, and falling back to whitespace. With
+// decimalComma, commas belong to the numbers and are not considered.
+func detectDelimiter(line string, decimalComma bool) rune {
+	for _, d := range []rune{',', '\t', ';'} {
+		if d == ',' && decimalComma {
+			continue
+		}
+		if strings.ContainsRune(line, d) {
+			return d
+		}
+	}
+	return 0
+}
+
+// splitFields splits a line on delim, or on runs of whitespace when delim is
+// zero. Delimiters inside double quotes are ignored, and surrounding quotes
+// and spaces are stripped from each field. Empty fields are kept wherever they
+// appear, since with any delimiter an empty field is a missing value; files
+// that end every line with a delimiter are handled by lineParser.
+func splitFields(line string, delim rune) []string {
+	if delim == 0 {
+		return strings.Fields(line)
+	}
+
+	var (
+		fields   []string
+		field    strings.Builder
+		inQuotes bool
+	)
+	for _, r := range line {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case r == delim && !inQuotes:
+			fields = append(fields, strings.TrimSpace(field.String()))
+			field.Reset()
+		default:
+			field.WriteRune(r)
+		}
+	}
+	fields = append(fields, strings.TrimSpace(field.String()))
+	return fields
+}
+
+// normalizeDecimals rewrites the fields holding a number with a decimal comma,
+// such as "1,5" or "-2,5e3", into the "1.5" form that strconv parses. Other
+// fields, such as column names or timestamps, are left as they are.
+func normalizeDecimals(fields []string) {
+	for i, f := range fields {
+		if !strings.Contains(f, ",") {
+			continue
+		}
+		s := strings.Replace(f, ",", ".", 1)
+		if _, err := strconv.ParseFloat(s, 64); err == nil {
+			fields[i] = s
+		}
+	}
+}
+
+// parseLine attempts to parse the fields of one line into an X value and one
+// or more Y values:
+//
+//	(1) a single float is treated as Y, with X = lineIndex;
+//	(2) two or more floats are treated as X followed by Y values.
+//
+// When columns is non-empty, only those field indices are used as Y values.
+// When xtime is non-empty, X is a timestamp in that layout (see parseX).
+func parseLine(fields []string, lineIndex float64, columns []int, xtime string) (float64, []float64, error) {
+	switch {
+	case len(fields) == 0:
+		return 0, nil, fmt.Errorf("no values")
+
+	case len(fields) == 1 && len(columns) == 0:
+		// One field => interpret as Y, with X = lineIndex
+		y, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid Y value %q", fields[0])
+		}
+		return lineIndex, []float64{y}, nil
+	}
+
+	// Two or more fields => interpret as (X, Y1, Y2, ...)
+	x, err := parseX(fields[0], xtime)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	ys, err := parseValues(fields, columns)
+	if err != nil {
+		return 0, nil, err
+	}
+	return x, ys, nil
+}
+
+// resolveColumns returns the indices of the named X and Y columns among the
+// header names, the X column first. Field 0 is X when x is empty, and every
+// other column is Y when ys is empty.
+func resolveColumns(names []string, x string, ys []string) ([]int, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no header to look up column names in")
+	}
+	index := func(name string) (int, error) {
+		for i, n := range names {
+			if n == strings.TrimSpace(name) {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("column %q not found in header %q", name, strings.Join(names, " "))
+	}
+
+	xcol := 0
+	if x != "" {
+		var err error
+		if xcol, err = index(x); err != nil {
+			return nil, err
+		}
+	}
+	cols := []int{xcol}
+	if ys == nil {
+		for i := range names {
+			if i != xcol {
+				cols = append(cols, i)
+			}
+		}
+		return cols, nil
+	}
+	for _, y := range ys {
+		col, err := index(y)
+		if err != nil {
+			return nil, err
+		}
+		cols = append(cols, col)
+	}
+	return cols, nil
+}
+
+// selectFields returns the fields at the given indices, in order.
+func selectFields(fields []string, indices []int) ([]string, error) {
+	selected := make([]string, len(indices))
+	for i, idx := range indices {
+		if idx >= len(fields) {
+			return nil, fmt.Errorf("field %d out of range, got %d fields", idx, len(fields))
+		}
+		selected[i] = fields[idx]
+	}
+	return selected, nil
+}
+
+// timeLayouts are the named layouts accepted by -xtime in place of a Go
+// time layout.
+var timeLayouts = map[string]string{
+	"iso":      "2006-01-02T15:04:05",
+	"rfc3339":  time.RFC3339,
+	"datetime": time.DateTime,
+	"date":     time.DateOnly,
+}
+
+// parseX parses an X value. With a non-empty layout, either a time.Parse
+// layout or a name from timeLayouts, the field is a timestamp and X is its
+// time in seconds since the Unix epoch. Timestamps without a zone are UTC.
+func parseX(field, layout string) (float64, error) {
+	if layout == "" {
+		x, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid X value %q", field)
+		}
+		return x, nil
+	}
+
+	if named, ok := timeLayouts[layout]; ok {
+		layout = named
+	}
+	t, err := time.Parse(layout, field)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q for layout %q", field, layout)
+	}
+	return float64(t.UnixNano()) / 1e9, nil
+}
+
+// parseBarLine interprets the fields of a -bar line as a category name followed
+// by one value per series.
+func parseBarLine(fields []string, columns []int) (string, []float64, error) {
+	if len(fields) < 2 {
+		return "", nil, fmt.Errorf("expected a category and at least one value")
+	}
+	ys, err := parseValues(fields, columns)
+	if err != nil {
+		return "", nil, err
+	}
+	return fields[0], ys, nil
+}
+
+// parseValues parses the Y values in the given fields, or in every field after
+// the first when columns is empty. Empty fields are missing values and yield
+// missingValue.
+func parseValues(fields []string, columns []int) ([]float64, error) {
+	if len(columns) == 0 {
+		for i := 1; i < len(fields); i++ {
+			columns = append(columns, i)
+		}
+	}
+
+	ys := make([]float64, len(columns))
+	for i, c := range columns {
+		if c < 0 || c >= len(fields) {
+			return nil, fmt.Errorf("column %d out of range, got %d values", c, len(fields))
+		}
+		if fields[c] == "" {
+			// An empty field between delimiters is a missing value
+			ys[i] = missingValue
+			continue
+		}
+		y, err := strconv.ParseFloat(fields[c], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Y value %q in column %d", fields[c], c)
+		}
+		ys[i] = y
+	}
+	return ys, nil
+}
+
+// -----------------------------------------------------------------------------
+// Creating and Saving the Plot
+// -----------------------------------------------------------------------------
+
+// imageFormats maps each supported output format to whether it is a raster
+// format that can be decoded back into an image.
+var imageFormats = map[string]bool{
+	"png":  true,
+	"jpg":  true,
+	"jpeg": true,
+	"tif":  true,
+	"tiff": true,
+	"svg":  false,
+	"pdf":  false,
+	"eps":  false,
+}
+
+// createPlot builds a plot from the data series and saves it to each of
+// outFiles in the format implied by its extension. It returns the rendered
+// image for terminal display, as described for saveFigures.
+func createPlot(data Dataset, outFiles []string, cfg Config) (image.Image, error) {
+	start := time.Now()
+	p, err := newPlot(data, cfg)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Timings.since("build", start)
+
+	// Save the plot with the given width/height
+	img, err := savePlot(p, outFiles, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("save plot: %w", err)
+	}
+	return img, nil
+}
+
+// newPlot builds a plot from the data series.
+// Multiple series are drawn in distinct colors and identified in a legend.
+func newPlot(data Dataset, cfg Config) (*plot.Plot, error) {
+	if cfg.Sort || cfg.Transform != "" {
+		series := make([]Series, len(data.Series))
+		for i, s := range data.Series {
+			if cfg.Sort {
+				s.Points = sortByX(s.Points)
+			}
+			if cfg.Transform != "" {
+				s.Points = applyTransform(s.Points, cfg.Transform)
+			}
+			series[i] = s
+		}
+		data.Series = series
+	}
+	// Directive labels describe the data's columns, so they go with them
+	// when the axes are transposed
+	data.XLabel = firstNonEmpty(data.Meta.XLabel, data.XLabel)
+	data.YLabel = firstNonEmpty(data.Meta.YLabel, data.YLabel)
+	if cfg.Transpose {
+		data = transposeData(data)
+	}
+
+	p := plot.New()
+	p.Title.Text = firstNonEmpty(cfg.Title, data.Meta.Title, defaultTitle)
+	p.X.Label.Text = firstNonEmpty(cfg.XLabel, data.XLabel, "X")
+	if cfg.XTime != "" {
+		p.X.Label.Text = firstNonEmpty(cfg.XLabel, data.XLabel, "Time")
+	}
+	p.Y.Label.Text = firstNonEmpty(cfg.YLabel, data.YLabel, "Y")
+	if cfg.Hist {
+		// The values are binned along X and counted up Y
+		p.X.Label.Text = firstNonEmpty(cfg.XLabel, data.YLabel, "Value")
+		p.Y.Label.Text = firstNonEmpty(cfg.YLabel, "Count")
+	}
+	if cfg.Box {
+		// The samples are named along X and their values spread up Y
+		p.X.Label.Text = cfg.XLabel
+		p.Y.Label.Text = firstNonEmpty(cfg.YLabel, data.YLabel, "Value")
+	}
+
+	applyTheme(p, cfg)
+	applyFontSizes(p, cfg)
+
+	// The series bounding a band are drawn as the band alone
+	var band []Series
+	if cfg.Band != nil {
+		var err error
+		if data.Series, band, err = takeBand(data.Series, cfg.Band); err != nil {
+			return nil, err
+		}
+		if cfg.LogY {
+			if err := checkPositive(band, "Y", func(pt Point) float64 { return pt.Y }); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	series, y2, err := assignY2(data.Series, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := configureAxes(p, series, cfg); err != nil {
+		return nil, err
+	}
+	cmap, err := newColorMap(series, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Several series are told apart in a legend unless it is turned off, or
+	// named along the X axis instead
+	legend := len(series)+min(len(band), 1) > 1 && cfg.LegendPos != "none" && !cfg.Box
+	if band != nil {
+		// The band takes the color of the first series, beneath its line
+		c := cfg.Colors.Line
+		if data.Meta.Color != nil && !cfg.Explicit["line-color"] {
+			c = data.Meta.Color
+		}
+		if len(series) > 1 {
+			c = palettes[cfg.Palette].colorForSeries(0)
+		}
+		if err := addBand(p, band[0], band[1], fade(c, 0x40), legend, cfg); err != nil {
+			return nil, fmt.Errorf("band between %s and %s: %w", band[0].Label, band[1].Label, err)
+		}
+	}
+	for i, s := range series {
+		// A single series keeps the configured colors; several get one each
+		lineColor, scatterColor := cfg.Colors.Line, cfg.Colors.Scatter
+		if data.Meta.Color != nil && !cfg.Explicit["line-color"] {
+			lineColor = data.Meta.Color
+		}
+		if len(series) > 1 {
+			lineColor = palettes[cfg.Palette].colorForSeries(i)
+			scatterColor = lineColor
+		}
+		lineColor, scatterColor, scfg := styleSeries(s, lineColor, scatterColor, cfg)
+
+		// Statistics go beneath the data
+		if cfg.Stats != "" {
+			if err := addStats(p, s, data.Series[i], lineColor, cfg); err != nil {
+				return nil, fmt.Errorf("statistics of %s: %w", s.Label, err)
+			}
+		}
+
+		if cfg.Bar {
+			if err := addBars(p, s, i, len(series), lineColor, legend, cfg); err != nil {
+				return nil, fmt.Errorf("bar chart of %s: %w", s.Label, err)
+			}
+			continue
+		}
+		if cfg.Hist {
+			if err := addHist(p, s, lineColor, legend, cfg); err != nil {
+				return nil, fmt.Errorf("histogram of %s: %w", s.Label, err)
+			}
+			continue
+		}
+		if cfg.Box {
+			if err := addBox(p, s, i, len(series), lineColor, cfg); err != nil {
+				return nil, fmt.Errorf("box plot of %s: %w", s.Label, err)
+			}
+			continue
+		}
+
+		// When smoothing, the line follows the smoothed curve while the raw
+		// data stays visible as faint scatter points
+		points, linePoints := s.Points, s.Points
+		if cfg.Smooth > 0 || cfg.SmoothMedian > 0 {
+			if cfg.Smooth > 0 {
+				linePoints = smooth(s.Points, cfg.Smooth)
+			} else {
+				linePoints = medianFilter(s.Points, cfg.SmoothMedian)
+			}
+			scatterColor = fade(scatterColor, 0x60)
+		}
+
+		// Thin very large series to the points that keep their shape; at
+		// that density scatter points would only bury the line. Streamed
+		// series were already thinned while reading.
+		showPoints := !cfg.NoPoints && (!cfg.Stream || cfg.ScatterOnly)
+		if cfg.MaxPoints > 0 && len(points) > cfg.MaxPoints {
+			points = downsampleLTTB(finitePoints(points), cfg.MaxPoints)
+			linePoints = downsampleLTTB(finitePoints(linePoints), cfg.MaxPoints)
+			showPoints = cfg.ScatterOnly
+		}
+
+		// Denser scatter points merge into a blob and slow drawing down, so
+		// unless they are all that is drawn, or carry the colormap, the line
+		// is left to show the data alone
+		if showPoints && !cfg.ScatterOnly && cmap == nil && cfg.ScatterLimit > 0 && len(points) > cfg.ScatterLimit {
+			showPoints = false
+			logInfo(cfg, "Hiding the scatter points of %s: %d points exceed -scatter-threshold %d", s.Label, len(points), cfg.ScatterLimit)
+		}
+		if cfg.Clip {
+			points, linePoints = clipPoints(points, cfg), clipPoints(linePoints, cfg)
+		}
+
+		lines, scatter, err := createPlotters(toXYs(linePoints), toXYs(points), lineColor, scatterColor, i, scfg)
+		if err != nil {
+			return nil, fmt.Errorf("creating plotters for %s: %w", s.Label, err)
+		}
+		if cmap != nil {
+			colorByZ(scatter, points, cmap)
+		}
+
+		// The filled area goes beneath everything else
+		if cfg.Fill {
+			fillColor := lineColor
+			if cfg.Colors.Fill != nil && len(series) == 1 {
+				fillColor = cfg.Colors.Fill
+			}
+			for _, line := range lines {
+				fill, err := createFill(line.XYs, fillColor, cfg)
+				if err != nil {
+					return nil, fmt.Errorf("creating fill for %s: %w", s.Label, err)
+				}
+				p.Add(fill)
+			}
+		}
+
+		// Error bars go beneath the line and points
+		if cfg.ErrorBars {
+			bars, err := createErrorBars(points, lineColor, cfg)
+			if err != nil {
+				return nil, fmt.Errorf("creating error bars for %s: %w", s.Label, err)
+			}
+			p.Add(bars)
+		}
+
+		// Add the line and/or scatter plotter to the plot
+		var thumbs []plot.Thumbnailer
+		if !cfg.ScatterOnly && len(lines) > 0 {
+			for _, line := range lines {
+				p.Add(line)
+			}
+			thumbs = append(thumbs, lines[0])
+		}
+		if showPoints {
+			p.Add(scatter)
+			thumbs = append(thumbs, scatter)
+		}
+		if legend {
+			p.Legend.Add(s.Label, thumbs...)
+		}
+
+		if cfg.Fit != "" {
+			fitColor := defaultColors.fit
+			if len(series) > 1 {
+				fitColor = lineColor
+			}
+			if err := addFit(p, s, fitColor, legend, cfg); err != nil {
+				return nil, fmt.Errorf("fitting %s: %w", s.Label, err)
+			}
+		}
+	}
+
+	if err := addRefLines(p, cfg); err != nil {
+		return nil, err
+	}
+	if err := addAnnotations(p, cfg); err != nil {
+		return nil, err
+	}
+	if y2 != nil {
+		p.Add(y2)
+	}
+
+	if cfg.Bar {
+		// Leave half a slot beside the outer bars, which the chart's data
+		// range does not include
+		p.NominalX(data.Categories...)
+		p.X.Min, p.X.Max = -0.5, float64(len(data.Categories))-0.5
+	}
+	if cfg.Box {
+		names := make([]string, len(series))
+		for i, s := range series {
+			names[i] = s.Label
+		}
+		p.NominalX(names...)
+		p.X.Min, p.X.Max = -0.5, float64(len(series))-0.5
+	}
+
+	// Outliers are left outside the range of the axis showing the Y values
+	if cfg.ClipOutliers {
+		if cfg.Transpose {
+			clipOutliers(&p.X, series, func(pt Point) float64 { return pt.X }, cfg.LogX)
+		} else {
+			clipOutliers(&p.Y, series, func(pt Point) float64 { return pt.Y }, cfg.LogY)
+		}
+	}
+
+	// Fixed bounds override the ranges gathered from the plotters above
+	if err := applyAxisRange(&p.X, "X", cfg.XMin, cfg.XMax, cfg.LogX); err != nil {
+		return nil, err
+	}
+	if err := applyAxisRange(&p.Y, "Y", cfg.YMin, cfg.YMax, cfg.LogY); err != nil {
+		return nil, err
+	}
+
+	if legend {
+		placeLegend(p, y2, cfg)
+	}
+
+	if cfg.Equal {
+		equalizeAxes(p, cfg)
+	}
+	return p, nil
+}
+
+// savePlot writes p to each of outFiles in the format implied by its
+// extension. Raster formats are rendered at cfg.DPI; vector formats are
+// resolution-independent.
+func savePlot(p *plot.Plot, outFiles []string, cfg Config) (image.Image, error) {
+	return saveFigures(outFiles, cfg, p.Draw)
+}
+
+// saveFigures saves the figure drawn by drawFn to each of outFiles with
+// saveFigure. Every file is attempted, and the errors of those that failed
+// are returned together. The image rendered for the first raster file is
+// returned so that it can be displayed without reading it back; it is nil
+// when only vector formats are saved. With no outFiles, the figure is only
+// rendered to the image.
+func saveFigures(outFiles []string, cfg Config, drawFn func(draw.Canvas)) (image.Image, error) {
+	if len(outFiles) == 0 {
+		return rasterFigure(cfg, drawFn).Image(), nil
+	}
+
+	var img image.Image
+	var errs []error
+	for _, outFile := range outFiles {
+		fileImg, err := saveFigure(outFile, cfg, drawFn)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", outFile, err))
+		}
+		if img == nil {
+			img = fileImg
+		}
+	}
+	return img, errors.Join(errs...)
+}
+
+// saveFigure renders a cfg.Width by cfg.Height image with drawFn and writes it
+// to outFile like savePlot, returning the image for a raster format.
+func saveFigure(outFile string, cfg Config, drawFn func(draw.Canvas)) (image.Image, error) {
+	f, err := os.Create(outFile)
+	if err != nil {
+		return nil, err
+	}
+	format := strings.TrimPrefix(filepath.Ext(outFile), ".")
+	img, err := writeFigure(f, format, cfg, drawFn)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return img, f.Close()
+}
+
+// renderDPI returns the resolution raster output is rendered at: cfg.DPI, or
+// hidpiFactor times that with cfg.HiDPI.
+func renderDPI(cfg Config) int {
+	if cfg.HiDPI {
+		return cfg.DPI * hidpiFactor
+	}
+	return cfg.DPI
+}
+
+// rasterFigure renders a cfg.Width by cfg.Height image with drawFn at the
+// resolution given by renderDPI.
+func rasterFigure(cfg Config, drawFn func(draw.Canvas)) *vgimg.Canvas {
+	w, h := vg.Points(float64(cfg.Width)), vg.Points(float64(cfg.Height))
+	c := vgimg.NewWith(vgimg.UseWH(w, h), vgimg.UseDPI(renderDPI(cfg)), vgimg.UseBackgroundColor(figureBackground(cfg)))
+	defer cfg.Timings.since("draw", time.Now())
+	drawFn(draw.New(c))
+	return c
+}
+
+// writeFigure renders a cfg.Width by cfg.Height image with drawFn and writes it
+// to w encoded in the named format, one of the keys of imageFormats. For a
+// raster format the rendered image is also returned.
+func writeFigure(w io.Writer, format string, cfg Config, drawFn func(draw.Canvas)) (image.Image, error) {
+	format = strings.ToLower(format)
+	var out io.WriterTo
+	var img image.Image
+	if imageFormats[format] {
+		c := rasterFigure(cfg, drawFn)
+		img = c.Image()
+		switch format {
+		case "jpg", "jpeg":
+			out = vgimg.JpegCanvas{Canvas: c}
+		case "tif", "tiff":
+			out = vgimg.TiffCanvas{Canvas: c}
+		default:
+			out = vgimg.PngCanvas{Canvas: c}
+		}
+	} else {
+		width, height := vg.Points(float64(cfg.Width)), vg.Points(float64(cfg.Height))
+		c, err := draw.NewFormattedCanvas(width, height, format)
+		if err != nil {
+			return nil, err
+		}
+		start := time.Now()
+		drawFn(draw.New(c))
+		cfg.Timings.since("draw", start)
+		out = c
+		if cfg.CMYK && format == "pdf" {
+			out = cmykPDF{canvas: c, exact: cmykColors(cfg)}
+		}
+	}
+	defer cfg.Timings.since("save", time.Now())
+	if _, err := out.WriteTo(w); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// configureAxes applies the axis scaling options in cfg to p, checking that
+// the data is compatible with them.
+func configureAxes(p *plot.Plot, series []Series, cfg Config) error {
+	if cfg.LogX {
+		if err := checkPositive(series, "X", func(pt Point) float64 { return pt.X }); err != nil {
+			return err
+		}
+		p.X.Scale = plot.LogScale{}
+		p.X.Tick.Marker = plot.LogTicks{}
+	}
+	if cfg.LogY {
+		if err := checkPositive(series, "Y", func(pt Point) float64 { return pt.Y }); err != nil {
+			return err
+		}
+		p.Y.Scale = plot.LogScale{}
+		p.Y.Tick.Marker = plot.LogTicks{}
+	}
+	if cfg.XTime != "" && !cfg.Hist {
+		p.X.Tick.Marker = plot.TimeTicks{Format: timeTickFormat(series), Time: plot.UTCUnixTime}
+	}
+	// Inverting the scale flips the drawing direction while the ticks
+	// keep labeling the true values
+	if cfg.XReverse {
+		p.X.Scale = plot.InvertedScale{Normalizer: p.X.Scale}
+	}
+	if cfg.YReverse {
+		p.Y.Scale = plot.InvertedScale{Normalizer: p.Y.Scale}
+	}
+	if cfg.TickFormat != "" {
+		if cfg.XTime == "" || cfg.Hist {
+			p.X.Tick.Marker = formattedTicks{p.X.Tick.Marker, cfg.TickFormat}
+		}
+		p.Y.Tick.Marker = formattedTicks{p.Y.Tick.Marker, cfg.TickFormat}
+	}
+	if !cfg.MinorTicks {
+		p.X.Tick.Marker = majorTicks{p.X.Tick.Marker}
+		p.Y.Tick.Marker = majorTicks{p.Y.Tick.Marker}
+	}
+	if !math.IsNaN(cfg.Margin) {
+		p.X.Padding = vg.Points(cfg.Margin)
+		p.Y.Padding = vg.Points(cfg.Margin)
+	}
+	return nil
+}
+
+// majorTicks wraps a Ticker, keeping only its labeled major ticks.
+type majorTicks struct {
+	plot.Ticker
+}
+
+// Ticks implements plot.Ticker.
+func (t majorTicks) Ticks(min, max float64) []plot.Tick {
+	var major []plot.Tick
+	for _, tick := range t.Ticker.Ticks(min, max) {
+		if !tick.IsMinor() {
+			major = append(major, tick)
+		}
+	}
+	return major
+}
+
+// timeTickFormat returns a time layout for X tick labels that shows the
+// detail needed to tell apart ticks across the X range of series.
+func timeTickFormat(series []Series) string {
+	xmin, xmax := math.Inf(1), math.Inf(-1)
+	for _, s := range series {
+		lo, hi := xRange(finitePoints(s.Points))
+		xmin, xmax = math.Min(xmin, lo), math.Max(xmax, hi)
+	}
+
+	const day = 24 * 60 * 60
+	switch span := xmax - xmin; {
+	case span > 3*365*day:
+		return "2006"
+	case span > 3*day:
+		return "2006-01-02"
+	case span > 3*60*60:
+		return "Jan 2 15:04"
+	case span > 3*60:
+		return "15:04"
+	default:
+		return "15:04:05"
+	}
+}
+
+// applyAxisRange sets the bounds of axis that are not NaN, leaving the others
+// auto-scaled, and checks that the resulting range is usable.
+func applyAxisRange(axis *plot.Axis, name string, lo, hi float64, logScale bool) error {
+	if math.IsNaN(lo) && math.IsNaN(hi) {
+		return nil
+	}
+	if !math.IsNaN(lo) {
+		axis.Min = lo
+	}
+	if !math.IsNaN(hi) {
+		axis.Max = hi
+	}
+	if axis.Min >= axis.Max {
+		return fmt.Errorf("%s axis minimum (%g) must be less than maximum (%g)", name, axis.Min, axis.Max)
+	}
+	if logScale && axis.Min <= 0 {
+		return fmt.Errorf("log %s axis requires a positive minimum, got %g", name, axis.Min)
+	}
+	return nil
+}
+
+// checkPositive returns an error naming the first point whose axis value, as
+// selected by value, is not strictly positive and so cannot be log-scaled.
+func checkPositive(series []Series, axis string, value func(Point) float64) error {
+	for _, s := range series {
+		for i, pt := range s.Points {
+			if v := value(pt); v <= 0 {
+				return fmt.Errorf("log %s axis requires positive values, but %s has %s = %g at point %d",
+					axis, s.Label, axis, v, i+1)
+			}
+		}
+	}
+	return nil
+}
+
+// placeLegend puts the legend of p in the corner, or at the top center, of
+// the data area as selected by cfg.LegendPos, keeping it clear of a
+// right-hand Y axis. Unless the Y range is fixed there, the Y axis is
+// extended so that the legend does not cover the data.
+func placeLegend(p *plot.Plot, y2 *y2Axis, cfg Config) {
+	p.Legend.Top = strings.HasPrefix(cfg.LegendPos, "top")
+	p.Legend.Left = strings.HasSuffix(cfg.LegendPos, "-left")
+
+	var y2Width vg.Length
+	if y2 != nil {
+		y2Width = y2.width(p)
+	}
+	switch {
+	case cfg.LegendPos == "top":
+		// The legend is drawn against the right edge; shift it left by half
+		// the space it leaves free
+		da := p.DataCanvas(draw.Canvas{Rectangle: vg.Rectangle{
+			Max: vg.Point{X: vg.Points(float64(cfg.Width)), Y: vg.Points(float64(cfg.Height))},
+		}})
+		r := p.Legend.Rectangle(da)
+		p.Legend.XOffs = -(da.Max.X - da.Min.X - (r.Max.X - r.Min.X) + y2Width) / 2
+	case !p.Legend.Left:
+		p.Legend.XOffs = -y2Width
+	}
+
+	// The legend's edge is at the Y maximum unless the axis is drawn reversed
+	atMax := p.Legend.Top != cfg.YReverse
+	fixed := cfg.YMin
+	if atMax {
+		fixed = cfg.YMax
+	}
+	if math.IsNaN(fixed) && !cfg.LogY {
+		reserveLegendSpace(p, atMax, cfg)
+	}
+}
+
+// reserveLegendSpace extends the Y axis beyond its maximum, or with atMax
+// unset its minimum, so that the edge of the data area where the legend is
+// drawn stays free of data.
+func reserveLegendSpace(p *plot.Plot, atMax bool, cfg Config) {
+	r := p.Legend.Rectangle(draw.Canvas{})
+	legendHeight := float64(r.Max.Y-r.Min.Y) + p.Legend.TextStyle.Font.Size.Points()
+
+	// The data area is somewhat smaller than the image; the full height keeps
+	// the headroom estimate conservative
+	frac := legendHeight / float64(cfg.Height)
+	if frac <= 0 || frac >= 0.5 {
+		return
+	}
+	extra := (p.Y.Max - p.Y.Min) * frac / (1 - frac)
+	if atMax {
+		p.Y.Max += extra
+	} else {
+		p.Y.Min -= extra
+	}
+}
+
+// equalizeAxes widens one axis range of p, about its center, so that a data
+// unit spans the same length on both axes of a cfg.Width by cfg.Height plot.
+// Fixed bounds may be widened too.
+func equalizeAxes(p *plot.Plot, cfg Config) {
+	canvas := draw.Canvas{Rectangle: vg.Rectangle{
+		Max: vg.Point{X: vg.Points(float64(cfg.Width)), Y: vg.Points(float64(cfg.Height))},
+	}}
+
+	// Widening changes the tick labels and so the data area; a second
+	// pass settles it
+	for range 2 {
+		da := p.DataCanvas(canvas)
+		w, h := float64(da.Max.X-da.Min.X), float64(da.Max.Y-da.Min.Y)
+		if w <= 0 || h <= 0 {
+			return
+		}
+		ux := (p.X.Max - p.X.Min) / w // Data units per point
+		uy := (p.Y.Max - p.Y.Min) / h
+		switch {
+		case ux > uy:
+			widen(&p.Y.Min, &p.Y.Max, ux*h)
+		case uy > ux:
+			widen(&p.X.Min, &p.X.Max, uy*w)
+		}
+	}
+}
+
+// widen sets the range [*lo, *hi] to the given span about its center.
+func widen(lo, hi *float64, span float64) {
+	mid := (*lo + *hi) / 2
+	*lo, *hi = mid-span/2, mid+span/2
+}
+
+// firstNonEmpty returns the first of its arguments that is not empty.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// toXYs converts our []Point slice into a plotter.XYs.
+func toXYs(points []Point) plotter.XYs {
+	pts := make(plotter.XYs, len(points))
+	for i, pt := range points {
+		pts[i].X = pt.X
+		pts[i].Y = pt.Y
+	}
+	return pts
+}
+
+// figureBackground returns the color the figure is drawn on: transparent with
+// cfg.Transparent, or else cfg.Colors.Background.
+func figureBackground(cfg Config) color.Color {
+	if cfg.Transparent {
+		return color.Transparent
+	}
+	return cfg.Colors.Background
+}
+
+// applyTheme colors the background of p and its title, axes, ticks, labels,
+// and legend text according to cfg.Colors.
+func applyTheme(p *plot.Plot, cfg Config) {
+	fg := cfg.Colors.Foreground
+	p.BackgroundColor = figureBackground(cfg)
+	p.Title.TextStyle.Color = fg
+	p.Legend.TextStyle.Color = fg
+	for _, axis := range []*plot.Axis{&p.X, &p.Y} {
+		axis.Color = fg
+		axis.Label.TextStyle.Color = fg
+		axis.Tick.Color = fg
+		axis.Tick.Label.Color = fg
+	}
+}
+
+// fontSizes returns the sizes in points of the axis label, legend, and
+// annotation text, of the title, and of the tick labels: those set in cfg, or
+// else gonum's defaults grown in proportion for plots larger than
+// fontScaleSize in both directions, so that text stays legible when a large
+// plot is shown scaled down.
+func fontSizes(cfg Config) (text, title, tick float64) {
+	scale := max(1, float64(min(cfg.Width, cfg.Height))/fontScaleSize)
+	text = cmp.Or(cfg.FontSize, defaultFontSize*scale)
+	title = cmp.Or(cfg.TitleSize, defaultFontSize*scale)
+	tick = cmp.Or(cfg.TickSize, defaultTickSize*scale)
+	return text, title, tick
+}
+
+// applyFontSizes sets the text sizes of p given by fontSizes.
+func applyFontSizes(p *plot.Plot, cfg Config) {
+	text, title, tick := fontSizes(cfg)
+	p.Title.TextStyle.Font.Size = vg.Points(title)
+	p.Legend.TextStyle.Font.Size = vg.Points(text)
+	for _, axis := range []*plot.Axis{&p.X, &p.Y} {
+		axis.Label.TextStyle.Font.Size = vg.Points(text)
+		axis.Tick.Label.Font.Size = vg.Points(tick)
+	}
+}
+
+// contrastColor returns black for a light background color and light gray for
+// a dark one. Translucent colors are judged as if over white, like the image
+// viewers that display them.
+func contrastColor(bg color.Color) color.Color {
+	c := color.NRGBAModel.Convert(bg).(color.NRGBA)
+	a := float64(c.A) / 255
+	over := func(v uint8) float64 { return a*float64(v)/255 + 1 - a }
+
+	// Relative luminance, ITU-R BT.709 weights
+	lum := 0.2126*over(c.R) + 0.7152*over(c.G) + 0.0722*over(c.B)
+	if lum > 0.5 {
+		return themes["light"].foreground
+	}
+	return themes["dark"].foreground
+}
+
+// fade returns c with its alpha replaced by alpha.
+func fade(c color.Color, alpha uint8) color.Color {
+	nc := color.NRGBAModel.Convert(c).(color.NRGBA)
+	nc.A = alpha
+	return nc
+}
+
+// createPlotters initializes line plotters over linePts and a scatter plotter
+// over scatterPts with the given colors and the configured line width, line
+// style and markers, for the series numbered index from 0. The line is split
+// into one plotter per run of finite points, so that NaN or Inf values leave
+// gaps; such values are left out of the scatter plotter.
+func createPlotters(linePts, scatterPts plotter.XYs, lineColor, scatterColor color.Color, index int, cfg Config) ([]*plotter.Line, *plotter.Scatter, error) {
+	style := cfg.LineStyle
+	if style == "cycle" {
+		style = lineStyleCycle[index%len(lineStyleCycle)]
+	}
+
+	// Create the line plotters
+	var lines []*plotter.Line
+	for _, seg := range splitAtGaps(linePts, cfg.GapThreshold, cfg.Transpose) {
+		line, err := plotter.NewLine(stepXYs(seg, cfg.Step))
+		if err != nil {
+			return nil, nil, fmt.Errorf("create line plotter: %w", err)
+		}
+		line.Color = lineColor
+		line.Width = vg.Points(cfg.LineWidth) // Apply line width
+		line.Dashes = lineStyles[style]
+		lines = append(lines, line)
+	}
+
+	// Create a scatter plotter
+	scatter, err := plotter.NewScatter(finiteXYs(scatterPts))
+	if err != nil {
+		return nil, nil, fmt.Errorf("create scatter plotter: %w", err)
+	}
+	scatter.GlyphStyle.Color = scatterColor
+	scatter.GlyphStyle.Radius = vg.Points(cfg.MarkerSize)
+	marker := cfg.Marker
+	if marker == "cycle" {
+		marker = markerCycle[index%len(markerCycle)]
+	}
+	scatter.GlyphStyle.Shape = markers[marker]
+
+	return lines, scatter, nil
+}
+
+// stepXYs turns pts into a staircase by inserting a corner point between
+// each pair of neighbours. With "pre" each Y value holds from the previous X
+// up to its own; with "post" it holds from its own X up to the next. Any other
+// mode returns pts unchanged.
+func stepXYs(pts plotter.XYs, mode string) plotter.XYs {
+	if (mode != "pre" && mode != "post") || len(pts) < 2 {
+		return pts
+	}
+	out := make(plotter.XYs, 0, 2*len(pts)-1)
+	out = append(out, pts[0])
+	for i := 1; i < len(pts); i++ {
+		corner := plotter.XY{X: pts[i].X, Y: pts[i-1].Y} // post
+		if mode == "pre" {
+			corner = plotter.XY{X: pts[i-1].X, Y: pts[i].Y}
+		}
+		out = append(out, corner, pts[i])
+	}
+	return out
+}
+
+// createFill returns a polygon shading the area between the line through pts
+// and Y = 0. Where the line crosses zero the area simply changes side, so
+// positive and negative parts are both shaded towards the axis.
+func createFill(pts plotter.XYs, c color.Color, cfg Config) (*plotter.Polygon, error) {
+	ring := make(plotter.XYs, 0, len(pts)+2)
+	ring = append(ring, pts...)
+	ring = append(ring, plotter.XY{X: pts[len(pts)-1].X}, plotter.XY{X: pts[0].X})
+
+	poly, err := plotter.NewPolygon(ring)
+	if err != nil {
+		return nil, err
+	}
+	poly.Color = fade(c, uint8(math.Round(cfg.FillOpacity*255)))
+	poly.LineStyle.Width = 0
+	return poly, nil
+}
+
+// createErrorBars returns a plotter drawing the Y errors of the finite points.
+func createErrorBars(points []Point, c color.Color, cfg Config) (*plotter.YErrorBars, error) {
+	points = finitePoints(points)
+	errs := make(plotter.YErrors, len(points))
+	for i, pt := range points {
+		errs[i].Low, errs[i].High = pt.ErrLow, pt.ErrHigh
+	}
+
+	bars, err := plotter.NewYErrorBars(struct {
+		plotter.XYs
+		plotter.YErrors
+	}{toXYs(points), errs})
+	if err != nil {
+		return nil, err
+	}
+	bars.LineStyle.Color = c
+	bars.LineStyle.Width = vg.Points(cfg.LineWidth)
+	return bars, nil
+}
+
+// splitAtGaps splits pts into runs of consecutive finite points. With a
+// positive maxGap, runs also break between neighbours whose X values are
+// further apart, taken from Y when the data is transposed.
+func splitAtGaps(pts plotter.XYs, maxGap float64, transposed bool) []plotter.XYs {
+	var (
+		segs  []plotter.XYs
+		start = -1
+	)
+	for i, pt := range pts {
+		finite := isFinite(pt.X) && isFinite(pt.Y)
+		switch {
+		case finite && start < 0:
+			start = i
+		case !finite && start >= 0:
+			segs = append(segs, pts[start:i])
+			start = -1
+		case finite && maxGap > 0 && i > start:
+			dx := pt.X - pts[i-1].X
+			if transposed {
+				dx = pt.Y - pts[i-1].Y
+			}
+			if math.Abs(dx) > maxGap {
+				segs = append(segs, pts[start:i])
+				start = i
+			}
+		}
+	}
+	if start >= 0 {
+		segs = append(segs, pts[start:])
+	}
+	return segs
+}
+
+// parseGap parses a -gap-threshold value: a non-negative number, or a
+// duration such as "90s" or "10m", given in seconds to match -xtime X values.
+func parseGap(s string) (float64, error) {
+	gap, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		d, derr := time.ParseDuration(s)
+		if derr != nil {
+			return 0, fmt.Errorf("expected a number or a duration such as 10m, got %q", s)
+		}
+		gap = d.Seconds()
+	}
+	if gap < 0 || !isFinite(gap) {
+		return 0, fmt.Errorf("must be a non-negative finite number, got %q", s)
+	}
+	return gap, nil
+}
+
+// finiteXYs returns the points of pts whose coordinates are both finite.
+func finiteXYs(pts plotter.XYs) plotter.XYs {
+	out := make(plotter.XYs, 0, len(pts))
+	for _, pt := range pts {
+		if isFinite(pt.X) && isFinite(pt.Y) {
+			out = append(out, pt)
+		}
+	}
+	return out
+}
+
+// -----------------------------------------------------------------------------
+// SIXEL Display
+// -----------------------------------------------------------------------------
+
+// displaySixel attempts to display the rendered plot via SIXEL,
+// adjusting image size if the user has specified a scale factor. With
+// cfg.Sixel set to "always" or "never", terminal detection is bypassed.
+func displaySixel(img image.Image, cfg Config) error {
+	if !sixelEnabled(cfg) {
+		return nil
+	}
+	return writeSixel(os.Stdout, img, cfg)
+}
+
+// writeSixel writes img to w as SIXEL graphics, scaled by cfg.Scale to the
+// size given by sentSize. SIXEL cannot ask the terminal to shrink an image, so
+// with cfg.HiDPI the extra pixels are sent as they are. Nor can it show
+// transparency, so with cfg.Transparent the image is first composited onto
+// cfg.Colors.Background.
+func writeSixel(w io.Writer, img image.Image, cfg Config) error {
+	if cfg.Transparent {
+		img = flattenImage(img, cfg.Colors.Background)
+	}
+	enc := sixel.NewEncoder(w)
+	if cfg.Scale != 1.0 {
+		enc.Width, enc.Height = sentSize(cfg)
+	}
+
+	if err := enc.Encode(img); err != nil {
+		return fmt.Errorf("encode SIXEL: %w", err)
+	}
+	return nil
+}
+
+// sixelEnabled reports whether SIXEL output should be produced, detecting
+// terminal support unless cfg.Sixel forces the decision.
+func sixelEnabled(cfg Config) bool {
+	switch cfg.Sixel {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	return isSixelSupported()
+}
+
+// isSixelSupported asks the terminal whether it supports SIXEL, falling back
+// to checking for a terminal type known to support it when the terminal
+// cannot be queried.
+func isSixelSupported() bool {
+	if ok, err := querySixelSupport(sixelQueryTimeout); err == nil {
+		return ok
+	}
+	return isSixelTerm()
+}
+
+// querySixelSupport sends the Primary Device Attributes query (ESC [ c) to the
+// controlling terminal and reports whether the reply advertises capability 4,
+// SIXEL graphics. The terminal is put in raw mode for the exchange and always
+// restored afterwards. An error is returned if the terminal cannot be opened
+// or does not answer within timeout.
+func querySixelSupport(timeout time.Duration) (bool, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return false, fmt.Errorf("open terminal: %w", err)
+	}
+	defer tty.Close()
+
+	// Fetch the descriptor via SyscallConn, since File.Fd would switch it to
+	// blocking mode and disable the read deadline below
+	conn, err := tty.SyscallConn()
+	if err != nil {
+		return false, fmt.Errorf("access terminal: %w", err)
+	}
+	var fd int
+	if err := conn.Control(func(f uintptr) { fd = int(f) }); err != nil {
+		return false, fmt.Errorf("access terminal: %w", err)
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return false, fmt.Errorf("set raw mode: %w", err)
+	}
+	defer term.Restore(fd, state)
+
+	if err := tty.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return false, fmt.Errorf("set read deadline: %w", err)
+	}
+	if _, err := tty.WriteString("\x1b[c"); err != nil {
+		return false, fmt.Errorf("send query: %w", err)
+	}
+
+	// The reply has the form ESC [ ? 62 ; 4 ; 22 c
+	var (
+		reply []byte
+		buf   [64]byte
+	)
+	for !bytes.HasSuffix(reply, []byte("c")) {
+		n, err := tty.Read(buf[:])
+		if err != nil {
+			return false, fmt.Errorf("read reply: %w", err)
+		}
+		reply = append(reply, buf[:n]...)
+	}
+	return parseDA1(string(reply)), nil
+}
+
+// parseDA1 reports whether a Primary Device Attributes reply lists
+// capability 4 (SIXEL). The first parameter is the terminal class and is not
+// a capability.
+func parseDA1(reply string) bool {
+	i := strings.Index(reply, "\x1b[?")
+	if i < 0 {
+		return false
+	}
+	params := strings.Split(strings.TrimSuffix(reply[i+3:], "c"), ";")
+	for _, p := range params[1:] {
+		if p == "4" {
+			return true
+		}
+	}
+	return false
+}
+
+// isSixelTerm checks for a terminal type known to support SIXEL.
+func isSixelTerm() bool {
 	term := strings.ToLower(os.Getenv("TERM"))
 	return strings.Contains(term, "xterm") ||
 		strings.Contains(term, "vt340") ||
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParseHexColor(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    color.Color
+		wantErr bool
+	}{
+		{in: "#abc", want: color.NRGBA{R: 0xaa, G: 0xbb, B: 0xcc, A: 0xff}},
+		{in: "abc", want: color.NRGBA{R: 0xaa, G: 0xbb, B: 0xcc, A: 0xff}},
+		{in: "#aabbcc", want: color.NRGBA{R: 0xaa, G: 0xbb, B: 0xcc, A: 0xff}},
+		{in: "#aabbcc80", want: color.NRGBA{R: 0xaa, G: 0xbb, B: 0xcc, A: 0x80}},
+		{in: "#ggg", wantErr: true},
+		{in: "#abcd", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseHexColor(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseHexColor(%q) = %v, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseHexColor(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseHexColor(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestReadDataNegativeColumn(t *testing.T) {
+	_, _, err := ReadData(strings.NewReader("1 2\n3 4\n"), ParseOptions{Column: -1})
+	if err == nil {
+		t.Fatal("ReadData with Column -1 succeeded, want an error")
+	}
+	if _, err := parseValues([]string{"1", "2"}, []int{-1}); err == nil {
+		t.Error("parseValues with column -1 succeeded, want an error")
+	}
+	cfg := DefaultConfig()
+	cfg.Columns = []int{-2}
+	if err := validateConfig(cfg); err == nil {
+		t.Error("validateConfig with -columns -2 succeeded, want an error")
+	}
+}
+
+func TestParseTSVEmptyFields(t *testing.T) {
+	tests := []struct {
+		line string
+		want []float64
+	}{
+		{line: "1\t2\t3\t4", want: []float64{2, 3, 4}},
+		{line: "1\t\t3\t4", want: []float64{missingValue, 3, 4}},
+		{line: "1\t2\t\t4", want: []float64{2, missingValue, 4}},
+		{line: "1\t\t\t4", want: []float64{missingValue, missingValue, 4}},
+	}
+	for _, tt := range tests {
+		fields := splitFields(tt.line, '\t')
+		if len(fields) != 4 {
+			t.Errorf("splitFields(%q) = %q, want 4 fields", tt.line, fields)
+			continue
+		}
+		got, err := parseValues(fields, nil)
+		if err != nil {
+			t.Errorf("parseValues(%q): %v", fields, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("parseValues(%q) = %v, want %v", fields, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if math.Float64bits(got[i]) != math.Float64bits(tt.want[i]) {
+				t.Errorf("parseValues(%q) = %v, want %v", fields, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestReadDataTSVEmptyMiddleColumn(t *testing.T) {
+	in := "1\t10\t100\n2\t\t200\n3\t30\t300\n"
+	points, parseErrs, err := ReadData(strings.NewReader(in), ParseOptions{Delimiter: "tab", Column: 2, NaN: "gap"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parseErrs) != 0 {
+		t.Errorf("ReadData skipped lines: %v", parseErrs)
+	}
+	want := []Point{{X: 1, Y: 100}, {X: 2, Y: 200}, {X: 3, Y: 300}}
+	if len(points) != len(want) {
+		t.Fatalf("ReadData = %v, want %v", points, want)
+	}
+	for i := range want {
+		if points[i] != want[i] {
+			t.Errorf("point %d = %v, want %v", i, points[i], want[i])
+		}
+	}
+}
+
+func TestMissingValuesIgnoreNaNOption(t *testing.T) {
+	in := []string{"1\t10", "2\t", "3\tnan", "4\t40"}
+	tests := []struct {
+		nan           string
+		wantYs        []float64 // NaN marks a gap
+		wantNonFinite int
+		wantErr       bool
+	}{
+		{nan: "skip", wantYs: []float64{10, 40}, wantNonFinite: 1},
+		{nan: "gap", wantYs: []float64{10, math.NaN(), math.NaN(), 40}},
+		{nan: "error", wantErr: true},
+	}
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.Quiet = true
+		cfg.Delimiter = "tab"
+		cfg.NaN = tt.nan
+		lp, err := newLineParser("input", cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.wantErr {
+			// Only the NaN read from the input is an error
+			if err := lp.parse(in[1]); err != nil {
+				t.Errorf("-nan %s: missing value: %v", tt.nan, err)
+			}
+			if err := lp.parse(in[2]); err == nil {
+				t.Errorf("-nan %s: NaN value parsed, want an error", tt.nan)
+			}
+			continue
+		}
+		for _, line := range in {
+			if err := lp.parse(line); err != nil {
+				t.Fatalf("-nan %s: %v", tt.nan, err)
+			}
+		}
+		if lp.data.NonFinite != tt.wantNonFinite {
+			t.Errorf("-nan %s: %d non-finite lines, want %d", tt.nan, lp.data.NonFinite, tt.wantNonFinite)
+		}
+		var ys []float64
+		for _, pt := range lp.data.Series[0].Points {
+			ys = append(ys, pt.Y)
+		}
+		if len(ys) != len(tt.wantYs) {
+			t.Errorf("-nan %s: Y values %v, want %v", tt.nan, ys, tt.wantYs)
+			continue
+		}
+		for i := range ys {
+			if ys[i] != tt.wantYs[i] && !(math.IsNaN(ys[i]) && math.IsNaN(tt.wantYs[i])) {
+				t.Errorf("-nan %s: Y values %v, want %v", tt.nan, ys, tt.wantYs)
+				break
+			}
+		}
+	}
+}
+
+func TestInvalidColorDirective(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Quiet = true
+	lp, err := newLineParser("input", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"# color: #abc", "# color: nope", "1 2", "2 3"} {
+		if err := lp.parse(line); err != nil {
+			t.Fatalf("parse(%q): %v", line, err)
+		}
+	}
+	if lp.data.Skipped != 0 {
+		t.Errorf("skipped %d lines (%s), want the directive ignored", lp.data.Skipped, lp.data.SkipReason)
+	}
+	if want := (color.NRGBA{R: 0xaa, G: 0xbb, B: 0xcc, A: 0xff}); lp.data.Meta.Color != want {
+		t.Errorf("color = %v, want %v from the valid directive", lp.data.Meta.Color, want)
+	}
+
+	var m Metadata
+	err = m.set("color", "nope")
+	if err == nil || !strings.Contains(err.Error(), `invalid color "nope" in color directive`) {
+		t.Errorf(`set("color", "nope") = %v, want an invalid color error`, err)
+	}
+}
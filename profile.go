@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"runtime/pprof"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+// Profiling
+// -----------------------------------------------------------------------------
+
+// stageTimes accumulates the time spent in each stage of a run for -profile.
+// Its methods do nothing on a nil receiver, so stages can be timed
+// unconditionally through Config.Timings.
+type stageTimes struct {
+	order []string // Stage names in the order first timed
+	spent map[string]time.Duration
+}
+
+// newStageTimes returns an empty set of stage timings.
+func newStageTimes() *stageTimes {
+	return &stageTimes{spent: make(map[string]time.Duration)}
+}
+
+// add adds d to the time spent in the named stage.
+func (t *stageTimes) add(stage string, d time.Duration) {
+	if t == nil {
+		return
+	}
+	if _, ok := t.spent[stage]; !ok {
+		t.order = append(t.order, stage)
+	}
+	t.spent[stage] += d
+}
+
+// since adds the time elapsed since start to the named stage. It suits defer:
+//
+//	defer cfg.Timings.since("build", time.Now())
+func (t *stageTimes) since(stage string, start time.Time) {
+	if t != nil {
+		t.add(stage, time.Since(start))
+	}
+}
+
+// report logs the time spent in each stage and its share of the total.
+func (t *stageTimes) report() {
+	if t == nil {
+		return
+	}
+	var total time.Duration
+	for _, d := range t.spent {
+		total += d
+	}
+	log.Printf("Profile:")
+	for _, stage := range t.order {
+		d := t.spent[stage]
+		share := 0.0
+		if total > 0 {
+			share = 100 * float64(d) / float64(total)
+		}
+		log.Printf("  %-8s %12v %6.1f%%", stage, d.Round(time.Microsecond), share)
+	}
+	log.Printf("  %-8s %12v", "total", total.Round(time.Microsecond))
+}
+
+// startCPUProfile starts writing a pprof CPU profile to filename and returns
+// a function that stops it and closes the file.
+func startCPUProfile(filename string) (stop func() error, err error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, fmt.Errorf("create CPU profile: %w", err)
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("start CPU profile: %w", err)
+	}
+	return func() error {
+		pprof.StopCPUProfile()
+		return f.Close()
+	}, nil
+}
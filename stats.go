@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"slices"
+	"text/tabwriter"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// -----------------------------------------------------------------------------
+// Summary Statistics
+// -----------------------------------------------------------------------------
+
+// Stats summarizes the Y values of a series.
+type Stats struct {
+	N          int     // Number of finite values
+	Min, Max   float64 // Smallest and largest value
+	Mean       float64 // Arithmetic mean
+	Median     float64 // Middle value, or the mean of the two middle values
+	StdDev     float64 // Population standard deviation
+	XMin, XMax float64 // Range of the finite X values of the points counted
+}
+
+// summarize computes the Stats of the finite Y values of points. Without any,
+// every field but N is NaN.
+func summarize(points []Point) Stats {
+	st := Stats{Min: math.Inf(1), Max: math.Inf(-1), XMin: math.Inf(1), XMax: math.Inf(-1)}
+	var sum float64
+	values := make([]float64, 0, len(points))
+	for _, pt := range points {
+		if !isFinite(pt.Y) {
+			continue
+		}
+		st.N++
+		sum += pt.Y
+		st.Min = math.Min(st.Min, pt.Y)
+		st.Max = math.Max(st.Max, pt.Y)
+		if isFinite(pt.X) {
+			st.XMin = math.Min(st.XMin, pt.X)
+			st.XMax = math.Max(st.XMax, pt.X)
+		}
+		values = append(values, pt.Y)
+	}
+	if st.N == 0 {
+		nan := math.NaN()
+		return Stats{Min: nan, Max: nan, Mean: nan, Median: nan, StdDev: nan, XMin: nan, XMax: nan}
+	}
+	st.Mean = sum / float64(st.N)
+	st.Median = median(values)
+
+	var ss float64
+	for _, pt := range points {
+		if isFinite(pt.Y) {
+			ss += (pt.Y - st.Mean) * (pt.Y - st.Mean)
+		}
+	}
+	st.StdDev = math.Sqrt(ss / float64(st.N))
+	return st
+}
+
+// printStats writes the Stats of each series of data to w, as an aligned
+// table or, with format "json", as a JSON array with one object per series.
+// Values that are undefined, such as the mean of no values, print as NaN in
+// the table and as null in JSON.
+func printStats(w io.Writer, data Dataset, format string) error {
+	if format == "json" {
+		type seriesStats struct {
+			Series string     `json:"series"`
+			N      int        `json:"n"`
+			Min    jsonNumber `json:"min"`
+			Max    jsonNumber `json:"max"`
+			Mean   jsonNumber `json:"mean"`
+			Median jsonNumber `json:"median"`
+			StdDev jsonNumber `json:"stddev"`
+			XMin   jsonNumber `json:"xmin"`
+			XMax   jsonNumber `json:"xmax"`
+		}
+		out := make([]seriesStats, len(data.Series))
+		for i, s := range data.Series {
+			st := summarize(s.Points)
+			out[i] = seriesStats{s.Label, st.N, jsonNumber(st.Min), jsonNumber(st.Max), jsonNumber(st.Mean),
+				jsonNumber(st.Median), jsonNumber(st.StdDev), jsonNumber(st.XMin), jsonNumber(st.XMax)}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "series\tn\tmin\tmax\tmean\tmedian\tstddev\txmin\txmax")
+	for _, s := range data.Series {
+		st := summarize(s.Points)
+		fmt.Fprintf(tw, "%s\t%d\t%.6g\t%.6g\t%.6g\t%.6g\t%.6g\t%.6g\t%.6g\n",
+			s.Label, st.N, st.Min, st.Max, st.Mean, st.Median, st.StdDev, st.XMin, st.XMax)
+	}
+	return tw.Flush()
+}
+
+// jsonNumber is a float64 that encodes the values JSON has no number for,
+// NaN and the infinities, as null.
+type jsonNumber float64
+
+// MarshalJSON implements the json.Marshaler interface.
+func (n jsonNumber) MarshalJSON() ([]byte, error) {
+	if !isFinite(float64(n)) {
+		return []byte("null"), nil
+	}
+	return json.Marshal(float64(n))
+}
+
+// addStats logs the statistics of raw, the series as read, and draws those
+// selected by cfg.Stats for s in color c: a dashed line at the mean for
+// "mean", dotted lines at the extremes for "minmax", and for "stddev" the mean
+// line over a shaded band one standard deviation either side. s differs from
+// raw when a -y2-series series is rescaled to the left axis.
+func addStats(p *plot.Plot, s, raw Series, c color.Color, cfg Config) error {
+	st := summarize(s.Points)
+	if st.N == 0 {
+		return fmt.Errorf("no finite Y values")
+	}
+	rs := summarize(raw.Points)
+	logInfo(cfg, "Stats for %s: n=%d min=%g max=%g mean=%g stddev=%g", raw.Label, rs.N, rs.Min, rs.Max, rs.Mean, rs.StdDev)
+
+	style := draw.LineStyle{Color: c, Width: vg.Points(1)}
+	switch cfg.Stats {
+	case "minmax":
+		style.Dashes = lineStyles["dotted"]
+		p.Add(refLine{value: st.Min, LineStyle: style}, refLine{value: st.Max, LineStyle: style})
+		return nil
+	case "stddev":
+		// A log axis cannot show the band below zero, so it stops at the
+		// smallest value there
+		lo, hi := st.Mean-st.StdDev, st.Mean+st.StdDev
+		if cfg.LogY && lo <= 0 {
+			lo = st.Min
+		}
+		p.Add(hBand{lo: lo, hi: hi, color: fade(c, 0x33)})
+	}
+	style.Dashes = lineStyles["dashed"]
+	p.Add(refLine{value: st.Mean, LineStyle: style})
+	return nil
+}
+
+// hBand is a plotter shading the whole width of the data area between two Y
+// values.
+type hBand struct {
+	lo, hi float64
+	color  color.Color
+}
+
+// Plot implements the plot.Plotter interface.
+func (b hBand) Plot(c draw.Canvas, plt *plot.Plot) {
+	_, trY := plt.Transforms(&c)
+	y0, y1 := trY(b.lo), trY(b.hi)
+	c.FillPolygon(b.color, c.ClipPolygonXY([]vg.Point{
+		{X: c.Min.X, Y: y0}, {X: c.Max.X, Y: y0},
+		{X: c.Max.X, Y: y1}, {X: c.Min.X, Y: y1},
+	}))
+}
+
+// DataRange implements the plot.DataRanger interface.
+func (b hBand) DataRange() (xmin, xmax, ymin, ymax float64) {
+	return math.Inf(1), math.Inf(-1), b.lo, b.hi
+}
+
+// outlierFences is the multiple of the interquartile range beyond the
+// quartiles at which -clip-outliers treats values as outliers (Tukey's fences).
+const outlierFences = 1.5
+
+// quantile returns the q-quantile of sorted, interpolating linearly between
+// the closest ranks. sorted must not be empty and must be in ascending order.
+func quantile(sorted []float64, q float64) float64 {
+	pos := q * float64(len(sorted)-1)
+	i := int(pos)
+	if i+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[i] + (pos-float64(i))*(sorted[i+1]-sorted[i])
+}
+
+// clipOutliers narrows the range of axis to the values of the series, as
+// selected by value, that lie within outlierFences interquartile ranges of the
+// quartiles. Bounds already inside that range are kept, and no points are
+// removed; beyond the range they are clipped by the data area. On a log axis
+// the quartiles are those of the values' logarithms.
+func clipOutliers(axis *plot.Axis, series []Series, value func(Point) float64, logScale bool) {
+	var values []float64
+	for _, s := range series {
+		for _, pt := range s.Points {
+			v := value(pt)
+			if logScale {
+				v = math.Log10(v)
+			}
+			if isFinite(v) {
+				values = append(values, v)
+			}
+		}
+	}
+	if len(values) == 0 {
+		return
+	}
+	slices.Sort(values)
+	q1, q3 := quantile(values, 0.25), quantile(values, 0.75)
+	lo, hi := q1-outlierFences*(q3-q1), q3+outlierFences*(q3-q1)
+	if logScale {
+		lo, hi = math.Pow(10, lo), math.Pow(10, hi)
+	}
+
+	// Without any spread, a range of a single value is left as it is
+	if lo < hi {
+		axis.Min, axis.Max = math.Max(axis.Min, lo), math.Min(axis.Max, hi)
+	}
+}
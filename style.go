@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+// Series Styles
+// -----------------------------------------------------------------------------
+
+// SeriesStyle overrides how the series read from one column is drawn. Zero
+// fields keep the palette color and the configured line width and style.
+type SeriesStyle struct {
+	Column int         // Field index of the series' Y values
+	Color  color.Color // Line and point color
+	Width  float64     // Line width in points
+	Style  string      // Line dash pattern, a key of lineStyles
+	Marker string      // Scatter point shape, a key of markers
+}
+
+// parseSeriesStyle parses a -series spec of comma-separated key=value pairs,
+// such as "col=2,color=#ff0000,width=2,style=dashed". The col key is required.
+func parseSeriesStyle(spec string) (SeriesStyle, error) {
+	var st SeriesStyle
+	for _, pair := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return SeriesStyle{}, fmt.Errorf("invalid series setting %q: expected key=value", pair)
+		}
+		switch key {
+		case "col":
+			col, err := strconv.Atoi(value)
+			if err != nil || col < 1 {
+				return SeriesStyle{}, fmt.Errorf("series column must be an integer >= 1, got %q", value)
+			}
+			st.Column = col
+		case "color":
+			c, err := parseHexColor(value)
+			if err != nil {
+				return SeriesStyle{}, err
+			}
+			st.Color = c
+		case "width":
+			w, err := strconv.ParseFloat(value, 64)
+			if err != nil || w <= 0 {
+				return SeriesStyle{}, fmt.Errorf("series width must be a positive number, got %q", value)
+			}
+			st.Width = w
+		case "style":
+			if _, ok := lineStyles[value]; !ok {
+				return SeriesStyle{}, fmt.Errorf("unknown line style %q", value)
+			}
+			st.Style = value
+		case "marker":
+			if _, ok := markers[value]; !ok {
+				return SeriesStyle{}, fmt.Errorf("unknown marker %q", value)
+			}
+			st.Marker = value
+		default:
+			return SeriesStyle{}, fmt.Errorf("unknown series setting %q: expected col, color, width, style, or marker", key)
+		}
+	}
+	if st.Column == 0 {
+		return SeriesStyle{}, fmt.Errorf("series spec %q needs col=N", spec)
+	}
+	return st, nil
+}
+
+// styleSeries returns the colors and configuration for drawing s, with the
+// -series overrides for its column, if any, applied to the given defaults.
+func styleSeries(s Series, lineColor, scatterColor color.Color, cfg Config) (color.Color, color.Color, Config) {
+	for _, st := range cfg.SeriesStyles {
+		if st.Column != s.Column {
+			continue
+		}
+		if st.Color != nil {
+			lineColor, scatterColor = st.Color, st.Color
+		}
+		if st.Width != 0 {
+			cfg.LineWidth = st.Width
+		}
+		if st.Style != "" {
+			cfg.LineStyle = st.Style
+		}
+		if st.Marker != "" {
+			cfg.Marker = st.Marker
+		}
+	}
+	return lineColor, scatterColor, cfg
+}
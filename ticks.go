@@ -0,0 +1,86 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"gonum.org/v1/plot"
+)
+
+// -----------------------------------------------------------------------------
+// Tick Labels
+// -----------------------------------------------------------------------------
+
+// engPrefixes are the SI prefixes for the powers of 1000 from 10^-12 to
+// 10^15, as used by the "eng" tick format.
+var engPrefixes = map[int]string{
+	-12: "p", -9: "n", -6: "µ", -3: "m", 0: "",
+	3: "k", 6: "M", 9: "G", 12: "T", 15: "P",
+}
+
+// formattedTicks wraps a Ticker, relabeling its labeled ticks with formatTick.
+type formattedTicks struct {
+	plot.Ticker
+	mode string
+}
+
+// Ticks implements plot.Ticker.
+func (t formattedTicks) Ticks(min, max float64) []plot.Tick {
+	ticks := t.Ticker.Ticks(min, max)
+	for i := range ticks {
+		if !ticks[i].IsMinor() {
+			ticks[i].Label = formatTick(ticks[i].Value, t.mode)
+		}
+	}
+	return ticks
+}
+
+// formatTick formats a tick value in the given -tick-format mode: "plain"
+// decimals such as 1200000, "sci" notation such as 1.2e6, "eng" notation
+// with an SI prefix such as 1.2M, or "comma" thousands separators such as
+// 1,200,000.
+func formatTick(v float64, mode string) string {
+	// Tick values are often computed with rounding noise, e.g. 0.30000000000000004
+	v, _ = strconv.ParseFloat(strconv.FormatFloat(v, 'g', 12, 64), 64)
+	if v == 0 || !isFinite(v) {
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+
+	switch mode {
+	case "sci":
+		exp := int(math.Floor(math.Log10(math.Abs(v))))
+		return formatMantissa(v/math.Pow10(exp)) + "e" + strconv.Itoa(exp)
+
+	case "eng":
+		exp := 3 * int(math.Floor(math.Log10(math.Abs(v))/3))
+		exp = max(-12, min(exp, 15))
+		return formatMantissa(v/math.Pow10(exp)) + engPrefixes[exp]
+
+	case "comma":
+		s := strconv.FormatFloat(v, 'f', -1, 64)
+		s, negative := strings.CutPrefix(s, "-")
+		whole, frac, hasFrac := strings.Cut(s, ".")
+		var b strings.Builder
+		if negative {
+			b.WriteByte('-')
+		}
+		for i, digit := range whole {
+			if i > 0 && (len(whole)-i)%3 == 0 {
+				b.WriteByte(',')
+			}
+			b.WriteRune(digit)
+		}
+		if hasFrac {
+			b.WriteString("." + frac)
+		}
+		return b.String()
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// formatMantissa formats the significand of scientific or engineering
+// notation to at most six significant digits.
+func formatMantissa(m float64) string {
+	return strconv.FormatFloat(m, 'g', 6, 64)
+}
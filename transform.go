@@ -0,0 +1,278 @@
+package main
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+// -----------------------------------------------------------------------------
+// Data Transformations
+// -----------------------------------------------------------------------------
+
+// transposeData returns a copy of data with the X and Y values of every point,
+// and the X and Y axis labels, swapped.
+func transposeData(data Dataset) Dataset {
+	out := data
+	out.XLabel, out.YLabel = data.YLabel, data.XLabel
+	out.Series = make([]Series, len(data.Series))
+	for i, s := range data.Series {
+		points := make([]Point, len(s.Points))
+		for j, pt := range s.Points {
+			pt.X, pt.Y = pt.Y, pt.X
+			points[j] = pt
+		}
+		s.Points = points
+		out.Series[i] = s
+	}
+	return out
+}
+
+// sortByX returns a copy of points ordered by X. The sort is stable, so points
+// sharing an X value keep their input order.
+func sortByX(points []Point) []Point {
+	out := slices.Clone(points)
+	slices.SortStableFunc(out, func(a, b Point) int { return cmp.Compare(a.X, b.X) })
+	return out
+}
+
+// applyTransform returns a copy of points with the Y values transformed as
+// named by kind: "cumsum" keeps a running total, "diff" takes the difference
+// from the previous point and so drops the first, "abs" takes magnitudes, and
+// "normalize" scales the values linearly onto [0, 1]. Non-finite values stay
+// in place, and cumsum skips over them.
+func applyTransform(points []Point, kind string) []Point {
+	out := make([]Point, 0, len(points))
+	switch kind {
+	case "cumsum":
+		var sum float64
+		for _, pt := range points {
+			if isFinite(pt.Y) {
+				sum += pt.Y
+				pt.Y = sum
+			}
+			out = append(out, pt)
+		}
+	case "diff":
+		for i := 1; i < len(points); i++ {
+			pt := points[i]
+			pt.Y -= points[i-1].Y
+			out = append(out, pt)
+		}
+	case "abs":
+		for _, pt := range points {
+			pt.Y = math.Abs(pt.Y)
+			out = append(out, pt)
+		}
+	case "normalize":
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for _, pt := range points {
+			if isFinite(pt.Y) {
+				lo, hi = math.Min(lo, pt.Y), math.Max(hi, pt.Y)
+			}
+		}
+		for _, pt := range points {
+			// A constant series has no range to scale; it maps to 0
+			if hi > lo {
+				pt.Y = (pt.Y - lo) / (hi - lo)
+			} else if isFinite(pt.Y) {
+				pt.Y = 0
+			}
+			out = append(out, pt)
+		}
+	default:
+		return points
+	}
+	return out
+}
+
+// clipPoints returns a copy of points in which those outside the axis bounds
+// fixed in cfg are replaced by NaN, so that lines break there instead of
+// running off the plot.
+func clipPoints(points []Point, cfg Config) []Point {
+	// A NaN bound fails every comparison and so leaves its side open
+	outside := func(v, lo, hi float64) bool { return v < lo || v > hi }
+	out := make([]Point, len(points))
+	for i, pt := range points {
+		if outside(pt.X, cfg.XMin, cfg.XMax) || outside(pt.Y, cfg.YMin, cfg.YMax) {
+			pt.X, pt.Y = math.NaN(), math.NaN()
+		}
+		out[i] = pt
+	}
+	return out
+}
+
+// smooth returns the centered moving average of points over window samples.
+// Near the ends the window shrinks symmetrically so that it stays centered,
+// leaving the first and last points unchanged. Non-finite values are left out
+// of the average, which is NaN only for a window without finite values. X
+// values are preserved.
+func smooth(points []Point, window int) []Point {
+	half := window / 2
+	out := make([]Point, len(points))
+	for i, pt := range points {
+		k := min(half, i, len(points)-1-i)
+
+		var (
+			sum float64
+			n   int
+		)
+		for j := i - k; j <= i+k; j++ {
+			if isFinite(points[j].Y) {
+				sum += points[j].Y
+				n++
+			}
+		}
+		y := math.NaN()
+		if n > 0 {
+			y = sum / float64(n)
+		}
+		out[i] = Point{X: pt.X, Y: y}
+	}
+	return out
+}
+
+// medianFilter returns the moving median of points over window samples, which
+// follows the trend like smooth but ignores isolated spikes. The window runs
+// from window/2 samples before each point to the rest after it, so an even
+// window reaches one sample further back, and the median of an even count is
+// the mean of the middle two values. Near the ends the window is shifted to
+// stay within the points, so that a spike there is still outvoted, and
+// non-finite values are left out of it. X values are preserved.
+func medianFilter(points []Point, window int) []Point {
+	out := make([]Point, len(points))
+	values := make([]float64, 0, window)
+	for i, pt := range points {
+		lo := max(min(i-window/2, len(points)-window), 0)
+		hi := min(lo+window, len(points))
+
+		values = values[:0]
+		for _, q := range points[lo:hi] {
+			if isFinite(q.Y) {
+				values = append(values, q.Y)
+			}
+		}
+		out[i] = Point{X: pt.X, Y: median(values)}
+	}
+	return out
+}
+
+// median returns the median of values, reordering them, or NaN when there
+// are none.
+func median(values []float64) float64 {
+	n := len(values)
+	if n == 0 {
+		return math.NaN()
+	}
+	slices.Sort(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return (values[n/2-1] + values[n/2]) / 2
+}
+
+// downsampleLTTB reduces points to threshold points with the
+// Largest-Triangle-Three-Buckets algorithm, which keeps the first and last
+// points and, from each bucket of the points between, the one forming the
+// largest triangle with its chosen neighbours. Peaks and troughs survive, so
+// the line looks the same at plot resolution. Points are assumed to be
+// finite and ordered by X; shorter inputs are returned unchanged.
+func downsampleLTTB(points []Point, threshold int) []Point {
+	if threshold < 3 || len(points) <= threshold {
+		return points
+	}
+
+	out := make([]Point, 0, threshold)
+	out = append(out, points[0])
+
+	// Buckets evenly divide the points between the first and last
+	size := float64(len(points)-2) / float64(threshold-2)
+	prev := points[0]
+	for b := 0; b < threshold-2; b++ {
+		start := int(float64(b)*size) + 1
+		end := int(float64(b+1)*size) + 1
+
+		// The third triangle corner is the average of the next bucket
+		nextStart, nextEnd := end, min(int(float64(b+2)*size)+1, len(points))
+		if b == threshold-3 {
+			nextStart, nextEnd = len(points)-1, len(points)
+		}
+		var avg Point
+		for _, pt := range points[nextStart:nextEnd] {
+			avg.X += pt.X
+			avg.Y += pt.Y
+		}
+		n := float64(nextEnd - nextStart)
+		avg.X, avg.Y = avg.X/n, avg.Y/n
+
+		best, bestArea := start, -1.0
+		for j := start; j < end; j++ {
+			pt := points[j]
+			area := math.Abs((prev.X-avg.X)*(pt.Y-prev.Y) - (prev.X-pt.X)*(avg.Y-prev.Y))
+			if area > bestArea {
+				best, bestArea = j, area
+			}
+		}
+		prev = points[best]
+		out = append(out, prev)
+	}
+	return append(out, points[len(points)-1])
+}
+
+// streamThinner downsamples a series while it is being read, so that memory
+// stays bounded however long the input. Each kept point stands for stride
+// input points: the one straying furthest in Y from the point kept before it,
+// so that spikes survive. Whenever the kept points reach twice the target they
+// are reduced to the target with LTTB, and stride doubles to match. Non-finite
+// points are dropped.
+type streamThinner struct {
+	target int
+	stride int
+	count  int // Input points in the current bucket
+}
+
+// newStreamThinner returns a thinner that keeps between target and 2*target
+// points once the input exceeds that.
+func newStreamThinner(target int) *streamThinner {
+	return &streamThinner{target: target, stride: 1}
+}
+
+// add adds pt to the thinned points and returns the updated slice. The last
+// point is provisional until its bucket is complete.
+func (t *streamThinner) add(points []Point, pt Point) []Point {
+	if !isFinite(pt.X) || !isFinite(pt.Y) {
+		return points
+	}
+
+	if t.count > 0 {
+		// Keep whichever point of the bucket strays furthest
+		last := len(points) - 1
+		if last < 1 || math.Abs(pt.Y-points[last-1].Y) > math.Abs(points[last].Y-points[last-1].Y) {
+			points[last] = pt
+		}
+	} else {
+		points = append(points, pt)
+		if len(points) >= 2*t.target {
+			points = downsampleLTTB(points, t.target)
+			t.stride *= 2
+		}
+	}
+
+	t.count++
+	if t.count >= t.stride {
+		t.count = 0
+	}
+	return points
+}
+
+// finitePoints returns the points whose coordinates are both finite, dropping
+// the NaN/Inf gap markers kept by -nan gap.
+func finitePoints(points []Point) []Point {
+	out := make([]Point, 0, len(points))
+	for _, pt := range points {
+		if isFinite(pt.X) && isFinite(pt.Y) {
+			out = append(out, pt)
+		}
+	}
+	return out
+}
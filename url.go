@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+// URL Input
+// -----------------------------------------------------------------------------
+
+// fetchTimeout bounds the whole of a URL download, including reading the body.
+const fetchTimeout = 30 * time.Second
+
+// isURL reports whether the input name is an HTTP or HTTPS URL rather than a
+// file name.
+func isURL(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// openURL fetches rawURL and returns the response body for reading. Responses
+// other than 200 OK are errors.
+func openURL(rawURL string) (io.ReadCloser, error) {
+	client := &http.Client{Timeout: fetchTimeout}
+	resp, err := client.Get(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("fetch URL: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("fetch URL: server returned %s", resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// urlFileName returns the last element of the URL's path, such as "data.csv"
+// for "https://example.com/files/data.csv?raw=1", or its host when the path
+// is empty. Output files and legend labels are named after it.
+func urlFileName(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "url"
+	}
+	if name := path.Base(u.Path); name != "." && name != "/" {
+		return name
+	}
+	return u.Hostname()
+}
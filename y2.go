@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// -----------------------------------------------------------------------------
+// Secondary Y Axis
+// -----------------------------------------------------------------------------
+
+// y2Axis is a plotter drawing a right-hand Y axis for the series selected by
+// -y2-series. gonum has a single Y axis, so those series are rescaled into the
+// range of the others, and this axis labels the original values.
+type y2Axis struct {
+	scale, offset float64 // Primary Y = secondary Y*scale + offset
+	label         string
+	minor         bool   // Whether to draw unlabeled minor ticks
+	tickFormat    string // Label format for formatTick; gonum's own when empty
+}
+
+// tickPad is the gap between the secondary axis' ticks and their labels.
+const tickPad = 2
+
+// assignY2 rescales the series numbered (from 1) in cfg.Y2Series so that their
+// Y range matches that of the other series, and marks them in their labels.
+// It returns the updated series and the axis that labels the rescaled values,
+// or nil when no series are selected.
+func assignY2(series []Series, cfg Config) ([]Series, *y2Axis, error) {
+	if len(cfg.Y2Series) == 0 {
+		return series, nil, nil
+	}
+
+	var primary, secondary []Series
+	for _, n := range cfg.Y2Series {
+		if n > len(series) {
+			return nil, nil, fmt.Errorf("-y2-series %d out of range, there are %d series", n, len(series))
+		}
+	}
+	for i, s := range series {
+		if slices.Contains(cfg.Y2Series, i+1) {
+			secondary = append(secondary, s)
+		} else {
+			primary = append(primary, s)
+		}
+	}
+	if len(primary) == 0 {
+		return nil, nil, fmt.Errorf("-y2-series selects every series, leaving none for the left axis")
+	}
+
+	pmin, pmax := yRange(primary)
+	smin, smax := yRange(secondary)
+	axis := &y2Axis{scale: (pmax - pmin) / (smax - smin), minor: cfg.MinorTicks, tickFormat: cfg.TickFormat}
+	axis.offset = pmin - smin*axis.scale
+
+	out := make([]Series, len(series))
+	for i, s := range series {
+		out[i] = s
+		if !slices.Contains(cfg.Y2Series, i+1) {
+			continue
+		}
+		if len(secondary) == 1 {
+			axis.label = s.Label
+		}
+		out[i].Label = s.Label + " (right)"
+		out[i].Points = make([]Point, len(s.Points))
+		for j, pt := range s.Points {
+			pt.Y = axis.toPrimary(pt.Y)
+			pt.ErrLow *= axis.scale
+			pt.ErrHigh *= axis.scale
+			out[i].Points[j] = pt
+		}
+	}
+	if cfg.Y2Label != "" {
+		axis.label = cfg.Y2Label
+	}
+	return out, axis, nil
+}
+
+// yRange returns the range of the finite Y values of series, widened around
+// a single value so that it is never empty.
+func yRange(series []Series) (ymin, ymax float64) {
+	ymin, ymax = math.Inf(1), math.Inf(-1)
+	for _, s := range series {
+		for _, pt := range finitePoints(s.Points) {
+			ymin = math.Min(ymin, pt.Y)
+			ymax = math.Max(ymax, pt.Y)
+		}
+	}
+	if math.IsInf(ymin, 1) {
+		return 0, 1
+	}
+	if ymin == ymax {
+		return ymin - 1, ymax + 1
+	}
+	return ymin, ymax
+}
+
+// toPrimary converts a secondary Y value to the primary axis.
+func (a *y2Axis) toPrimary(y float64) float64 {
+	return y*a.scale + a.offset
+}
+
+// toSecondary converts a primary Y value to the secondary axis.
+func (a *y2Axis) toSecondary(y float64) float64 {
+	return (y - a.offset) / a.scale
+}
+
+// ticks returns the secondary ticks across the final primary Y range of plt.
+func (a *y2Axis) ticks(plt *plot.Plot) []plot.Tick {
+	var ticker plot.Ticker = plot.DefaultTicks{}
+	if a.tickFormat != "" {
+		ticker = formattedTicks{ticker, a.tickFormat}
+	}
+	if !a.minor {
+		ticker = majorTicks{ticker}
+	}
+	return ticker.Ticks(a.toSecondary(plt.Y.Min), a.toSecondary(plt.Y.Max))
+}
+
+// Plot implements the plot.Plotter interface, drawing the axis line, ticks,
+// and labels just right of the data area in the style of the left axis.
+func (a *y2Axis) Plot(c draw.Canvas, plt *plot.Plot) {
+	_, trY := plt.Transforms(&c)
+	x := c.Max.X
+	c.StrokeLine2(plt.Y.LineStyle, x, c.Min.Y, x, c.Max.Y)
+
+	labelStyle := plt.Y.Tick.Label
+	labelStyle.XAlign = draw.XLeft
+	labelStyle.YAlign = draw.YCenter
+
+	var labelWidth vg.Length
+	for _, t := range a.ticks(plt) {
+		y := trY(a.toPrimary(t.Value))
+		if y < c.Min.Y-0.5 || y > c.Max.Y+0.5 {
+			continue
+		}
+		length := plt.Y.Tick.Length
+		if t.IsMinor() {
+			length /= 2
+		}
+		c.StrokeLine2(plt.Y.Tick.LineStyle, x, y, x+length, y)
+		if t.Label != "" {
+			c.FillText(labelStyle, vg.Point{X: x + plt.Y.Tick.Length + tickPad, Y: y}, t.Label)
+			labelWidth = max(labelWidth, labelStyle.Width(t.Label))
+		}
+	}
+
+	if a.label != "" {
+		style := plt.Y.Label.TextStyle
+		style.Rotation = -math.Pi / 2
+		style.XAlign = draw.XCenter
+		style.YAlign = draw.YBottom // Rotated, the text's bottom faces the axis
+		at := vg.Point{X: x + plt.Y.Tick.Length + tickPad + labelWidth + plt.Y.Label.Padding, Y: c.Center().Y}
+		c.FillText(style, at, a.label)
+	}
+}
+
+// GlyphBoxes implements the plot.GlyphBoxer interface, reserving room to the
+// right of the data area for the ticks and labels.
+func (a *y2Axis) GlyphBoxes(plt *plot.Plot) []plot.GlyphBox {
+	return []plot.GlyphBox{{X: 1, Y: 0.5, Rectangle: vg.Rectangle{Max: vg.Point{X: a.width(plt)}}}}
+}
+
+// width returns the room the axis takes up right of the data area.
+func (a *y2Axis) width(plt *plot.Plot) vg.Length {
+	width := plt.Y.Tick.Length + tickPad
+	var labelWidth vg.Length
+	for _, t := range a.ticks(plt) {
+		if t.Label != "" {
+			labelWidth = max(labelWidth, plt.Y.Tick.Label.Width(t.Label))
+		}
+	}
+	width += labelWidth
+	if a.label != "" {
+		width += plt.Y.Label.Padding + plt.Y.Label.TextStyle.Height(a.label)
+	}
+	return width
+}